@@ -0,0 +1,141 @@
+package main
+
+import (
+	"app/internal/database"
+	"app/internal/migrations"
+	"app/pkg/config"
+	"app/pkg/migrate"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// configMigrate is the configuration of the command, loaded by pkg/config
+type configMigrate struct {
+	// Db is the database to migrate
+	Db	*database.Config	`config:"db"`
+	// Dir is the directory of the migration files
+	Dir	string	`config:"dir" flag:"dir" usage:"directory of the migration files (create only)"`
+}
+
+// usage is the help of the command
+const usage = `usage: migrate [flags] <command>
+
+commands:
+  up             apply the pending migrations
+  down [n]       revert the last n applied migrations (1 by default)
+  status         list the migrations and whether they are applied
+  create <name>  write the files of a new migration in dir
+
+the database is configured like the server (config file, DB_MYSQL_* env vars or -db-* flags),
+up, down and status use the migrations embedded in the binary, migrate -h lists the flags
+`
+
+func main() {
+	// cfg (defaults < config file < env < flags)
+	cfg := &configMigrate{Db: database.DefaultConfig(), Dir: "internal/migrations"}
+	args, err := config.Load(cfg, os.Args[1:], os.LookupEnv)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			fmt.Fprint(os.Stderr, usage)
+			return
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	// run
+	if err := run(args[0], args[1:], cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run runs the command with its arguments
+func run(command string, args []string, cfg *configMigrate) (err error) {
+	// create does not need the database
+	if command == "create" {
+		if len(args) != 1 {
+			return fmt.Errorf("create needs a name")
+		}
+
+		var paths []string
+		paths, err = migrate.Create(cfg.Dir, args[0])
+		for _, path := range paths {
+			fmt.Println("created", path)
+		}
+		return
+	}
+
+	// migrator
+	ms, err := migrate.Load(migrations.FS)
+	if err != nil {
+		return
+	}
+	err = cfg.Db.Validate()
+	if err != nil {
+		return
+	}
+	ctx := context.Background()
+	db, err := database.Open(ctx, cfg.Db)
+	if err != nil {
+		return
+	}
+	defer db.Close()
+	migrator := migrate.NewMigrator(db, ms)
+
+	switch command {
+	case "up":
+		var applied []*migrate.Migration
+		applied, err = migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 0 {
+			steps, err = strconv.Atoi(args[0])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("down needs a positive amount of steps")
+			}
+		}
+
+		var reverted []*migrate.Migration
+		reverted, err = migrator.Down(ctx, steps)
+		for _, m := range reverted {
+			fmt.Printf("reverted %04d_%s\n", m.Version, m.Name)
+		}
+	case "status":
+		var statuses []*migrate.Status
+		statuses, err = migrator.Status(ctx)
+		if err != nil {
+			return
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.Applied {
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%04d\t%s\t%s\n", s.Migration.Version, s.Migration.Name, appliedAt)
+		}
+		err = tw.Flush()
+	default:
+		err = fmt.Errorf("unknown command %q\n\n%s", command, usage)
+	}
+	return
+}
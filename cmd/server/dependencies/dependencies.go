@@ -2,14 +2,30 @@ package dependencies
 
 import (
 	"app/cmd/server/handlers"
+	"app/cmd/server/middlewares"
+	"app/internal/database"
+	"app/internal/migrations"
+	"app/internal/products/service"
 	"app/internal/products/storage"
+	"app/internal/products/validator"
+	"app/pkg/jwt"
+	"app/pkg/metrics"
+	"app/pkg/migrate"
+	"app/pkg/trace"
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/go-sql-driver/mysql"
 )
 
 func NewApplication(cfg *Config) *Application {
@@ -22,18 +38,267 @@ var (
 )
 
 type ConfigServer struct {
-	Host string
-	Port int
+	Host string	`config:"host" env:"SERVER_HOST" flag:"host" usage:"host the server listens on"`
+	Port int	`config:"port" env:"SERVER_PORT" flag:"port" usage:"port the server listens on"`
+	// timeouts (0 means no timeout)
+	// -> max duration to read the headers of a request
+	ReadHeaderTimeout time.Duration	`config:"read_header_timeout" env:"SERVER_READ_HEADER_TIMEOUT" flag:"read-header-timeout" usage:"max duration to read the headers of a request"`
+	// -> max duration to read a whole request, body included
+	ReadTimeout time.Duration	`config:"read_timeout" env:"SERVER_READ_TIMEOUT" flag:"read-timeout" usage:"max duration to read a whole request"`
+	// -> max duration to write a response, it also bounds exports
+	WriteTimeout time.Duration	`config:"write_timeout" env:"SERVER_WRITE_TIMEOUT" flag:"write-timeout" usage:"max duration to write a response"`
+	// -> max duration a keep-alive connection waits for the next request
+	IdleTimeout time.Duration	`config:"idle_timeout" env:"SERVER_IDLE_TIMEOUT" flag:"idle-timeout" usage:"max duration a keep-alive connection waits for the next request"`
+	// -> duration the readiness fails on shutdown before the server stops accepting requests,
+	//    so the orchestrator stops routing traffic to it first
+	DrainDelay time.Duration	`config:"drain_delay" env:"SERVER_DRAIN_DELAY" flag:"drain-delay" usage:"duration the readiness fails on shutdown before the server stops accepting requests"`
+	// -> max duration to drain the in-flight requests on shutdown, then they are dropped
+	ShutdownTimeout time.Duration	`config:"shutdown_timeout" env:"SERVER_SHUTDOWN_TIMEOUT" flag:"shutdown-timeout" usage:"max duration to drain the in-flight requests on shutdown"`
 }
 func (c *ConfigServer) Addr() string {
 	return fmt.Sprintf("%s:%d", c.Host, c.Port)
 }
 
+// storage backends
+const (
+	// StorageMySQL stores products in the MySQL database configured by Db
+	StorageMySQL = "mysql"
+	// StorageMap stores products in memory (no database needed, data is lost on shutdown)
+	StorageMap = "map"
+)
+
+// ConfigDb is the configuration of the database
+type ConfigDb struct {
+	database.Config
+	// -> max duration of each storage operation (0 uses the storage default)
+	QueryTimeout time.Duration	`config:"query_timeout" env:"DB_MYSQL_QUERY_TIMEOUT" flag:"db-query-timeout" usage:"max duration of each storage operation (0 uses the storage default)"`
+	// -> apply the pending migrations on start
+	Migrate bool	`config:"migrate" env:"DB_MYSQL_MIGRATE" flag:"db-migrate" usage:"apply the pending migrations on start"`
+}
+
+// ConfigLog is the configuration of the logs
+type ConfigLog struct {
+	// Level is the minimum level of the logs: debug, info, warn or error
+	Level string	`config:"level" env:"LOG_LEVEL" flag:"log-level" usage:"minimum level of the logs: debug, info, warn or error"`
+	// Format is the format of the logs: text or json
+	Format string	`config:"format" env:"LOG_FORMAT" flag:"log-format" usage:"format of the logs: text or json"`
+}
+
+// Logger returns the logger of the configuration, writing to w
+// - the records logged with the context of a request have its request id and route
+func (c *ConfigLog) Logger(w io.Writer) (logger *slog.Logger, err error) {
+	var level slog.Level
+	err = level.UnmarshalText([]byte(c.Level))
+	if err != nil {
+		err = fmt.Errorf("log level %q must be debug, info, warn or error", c.Level)
+		return
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler
+	switch c.Format {
+	case "text":
+		h = slog.NewTextHandler(w, opts)
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	default:
+		err = fmt.Errorf("log format %q must be text or json", c.Format)
+		return
+	}
+
+	logger = slog.New(middlewares.NewHandlerLog(h))
+	return
+}
+
+// trace exporters
+const (
+	// TraceExporterNone disables tracing
+	TraceExporterNone = "none"
+	// TraceExporterStderr writes the spans to stderr, as JSON lines
+	// (not stdout, the spans would be mixed with the JSON logs)
+	TraceExporterStderr = "stderr"
+	// TraceExporterFile appends the spans to File, as JSON lines
+	TraceExporterFile = "file"
+)
+
+// ConfigTrace is the configuration of the traces
+type ConfigTrace struct {
+	// Exporter is the exporter of the spans: none, stderr or file
+	Exporter string	`config:"exporter" env:"TRACE_EXPORTER" flag:"trace-exporter" usage:"exporter of the spans: none, stderr or file"`
+	// File is the file the spans are appended to, with the file exporter
+	File string	`config:"file" env:"TRACE_FILE" flag:"trace-file" usage:"file the spans are appended to, with the file exporter"`
+}
+
+// Validate checks the exporter can be built
+func (c *ConfigTrace) Validate() (err error) {
+	switch c.Exporter {
+	case TraceExporterNone, TraceExporterStderr:
+	case TraceExporterFile:
+		if c.File == "" {
+			err = errors.New("trace file is required by the file exporter")
+			return
+		}
+	default:
+		err = fmt.Errorf("trace exporter %q must be none, stderr or file", c.Exporter)
+		return
+	}
+	return
+}
+
+// ConfigAuth is the configuration of the authentication of the products and admin routes
+// - secrets have no flag, so they do not show in the process list
+type ConfigAuth struct {
+	// Enabled requires authentication, it needs API keys, a JWT secret or a JWT public key
+	Enabled bool	`config:"enabled" env:"AUTH_ENABLED" flag:"auth-enabled" usage:"require authentication on the products and admin routes"`
+	// APIKeys are the accepted API keys, as name:key (the name is the principal of the requests with the key)
+	APIKeys []string	`config:"api_keys,secret" env:"AUTH_API_KEYS"`
+	// JWTSecret verifies the HS256 tokens
+	JWTSecret string	`config:"jwt_secret,secret" env:"AUTH_JWT_SECRET"`
+	// JWTPublicKeyFile is the PEM file of the RSA public key that verifies the RS256 tokens
+	JWTPublicKeyFile string	`config:"jwt_public_key_file" env:"AUTH_JWT_PUBLIC_KEY_FILE" flag:"auth-jwt-public-key-file" usage:"PEM file of the RSA public key that verifies the RS256 tokens"`
+	// JWTIssuer must be the iss claim of the tokens (empty accepts any issuer)
+	JWTIssuer string	`config:"jwt_issuer" env:"AUTH_JWT_ISSUER" flag:"auth-jwt-issuer" usage:"iss claim required in the tokens (empty accepts any issuer)"`
+	// JWTAudience must be in the aud claim of the tokens (empty accepts any audience)
+	JWTAudience string	`config:"jwt_audience" env:"AUTH_JWT_AUDIENCE" flag:"auth-jwt-audience" usage:"aud claim required in the tokens (empty accepts any audience)"`
+	// JWTLeeway is the clock skew tolerated on the exp and nbf claims
+	JWTLeeway time.Duration	`config:"jwt_leeway" env:"AUTH_JWT_LEEWAY" flag:"auth-jwt-leeway" usage:"clock skew tolerated on the exp and nbf claims"`
+}
+
+// min lengths of the secrets, so they can not be guessed
+const (
+	minLenAPIKey = 16
+	// -> RFC 7518 requires a key as long as the hash output
+	minLenJWTSecret = 32
+)
+
+// Auth returns the authentication middleware of the configuration, logging with logger
+func (c *ConfigAuth) Auth(logger *slog.Logger) (mw func(next http.Handler) http.Handler, err error) {
+	if len(c.APIKeys) == 0 && c.JWTSecret == "" && c.JWTPublicKeyFile == "" {
+		err = errors.New("auth requires api keys, a jwt secret or a jwt public key file, or auth.enabled set to false")
+		return
+	}
+
+	// api keys
+	keys := make(map[string]string, len(c.APIKeys))
+	for i, item := range c.APIKeys {
+		name, key, ok := strings.Cut(item, ":")
+		if !ok || name == "" {
+			err = fmt.Errorf("auth api key #%d must be name:key", i+1)
+			return
+		}
+		if len(key) < minLenAPIKey {
+			err = fmt.Errorf("auth api key %q must have at least %d characters", name, minLenAPIKey)
+			return
+		}
+		if _, ok := keys[key]; ok {
+			err = fmt.Errorf("auth api key %q is not unique", name)
+			return
+		}
+		keys[key] = name
+	}
+
+	// jwt
+	var verifier *jwt.Verifier
+	if c.JWTSecret != "" || c.JWTPublicKeyFile != "" {
+		cfg := &jwt.ConfigVerifier{Issuer: c.JWTIssuer, Audience: c.JWTAudience, Leeway: c.JWTLeeway}
+		if c.JWTSecret != "" {
+			if len(c.JWTSecret) < minLenJWTSecret {
+				err = fmt.Errorf("auth jwt secret must have at least %d characters", minLenJWTSecret)
+				return
+			}
+			cfg.SecretHS256 = []byte(c.JWTSecret)
+		}
+		if c.JWTPublicKeyFile != "" {
+			var b []byte
+			b, err = os.ReadFile(c.JWTPublicKeyFile)
+			if err != nil {
+				err = fmt.Errorf("auth jwt public key: %v", err)
+				return
+			}
+			cfg.PublicKeyRS256, err = jwt.ParseRSAPublicKeyPEM(b)
+			if err != nil {
+				err = fmt.Errorf("auth jwt public key %s: %v", c.JWTPublicKeyFile, err)
+				return
+			}
+		}
+		verifier = jwt.NewVerifier(cfg)
+	}
+
+	mw = middlewares.Auth(&middlewares.ConfigAuth{APIKeys: keys, Verifier: verifier, Logger: logger})
+	return
+}
+
+// Config is the configuration of the application, loaded by pkg/config
 type Config struct {
+	// storage backend (StorageMySQL by default)
+	Storage string	`config:"storage" env:"STORAGE_BACKEND" flag:"storage" usage:"storage backend: mysql or map"`
 	// database
-	DbMySQL *mysql.Config
+	Db *ConfigDb	`config:"db"`
 	// server
-	Server  *ConfigServer
+	Server  *ConfigServer	`config:"server"`
+	// logs
+	Log *ConfigLog	`config:"log"`
+	// traces
+	Trace *ConfigTrace	`config:"trace"`
+	// authentication
+	Auth *ConfigAuth	`config:"auth"`
+	// products
+	// -> allowed product types (empty allows any type)
+	ProductTypes []string	`config:"product_types" env:"PRODUCT_TYPES" flag:"product-types" usage:"comma separated allowed product types (empty allows any type)"`
+}
+
+// DefaultConfig returns the configuration used for the values that are not configured
+func DefaultConfig() *Config {
+	return &Config{
+		Storage: StorageMySQL,
+		Db: &ConfigDb{Config: *database.DefaultConfig()},
+		Log: &ConfigLog{Level: "info", Format: "json"},
+		Trace: &ConfigTrace{Exporter: TraceExporterNone},
+		Auth: &ConfigAuth{Enabled: true, JWTLeeway: 30 * time.Second},
+		Server: &ConfigServer{
+			Port: 8080,
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout: 30 * time.Second,
+			WriteTimeout: 60 * time.Second,
+			IdleTimeout: 120 * time.Second,
+			DrainDelay: 5 * time.Second,
+			ShutdownTimeout: 30 * time.Second,
+		},
+	}
+}
+
+// Validate checks the configuration can run the application
+func (c *Config) Validate() (err error) {
+	switch c.Storage {
+	case StorageMySQL:
+		err = c.Db.Validate()
+		if err != nil {
+			return
+		}
+	case StorageMap:
+	default:
+		err = fmt.Errorf("unknown storage %q", c.Storage)
+		return
+	}
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		err = fmt.Errorf("server port %d out of range", c.Server.Port)
+		return
+	}
+	_, err = c.Log.Logger(io.Discard)
+	if err != nil {
+		return
+	}
+	err = c.Trace.Validate()
+	if err != nil {
+		return
+	}
+	if c.Auth.Enabled {
+		_, err = c.Auth.Auth(slog.Default())
+		if err != nil {
+			return
+		}
+	}
+	return
 }
 
 type Application struct {
@@ -41,36 +306,208 @@ type Application struct {
 	cfg *Config
 }
 
+// Run serves the Product API until SIGINT or SIGTERM
+// - on a signal the server stops accepting connections and drains the in-flight requests
+//   within Server.ShutdownTimeout, then the database is closed
 func (a *Application) Run() (err error) {
+	// signals (they also interrupt the start)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	// dependencies
-	// -> database
-	var db *sql.DB
-	db, err = sql.Open("mysql", a.cfg.DbMySQL.FormatDSN())
+	// -> logger
+	logger, err := a.cfg.Log.Logger(os.Stdout)
 	if err != nil {
 		err = fmt.Errorf("%w. %s", ErrApplicationInternal, err.Error())
 		return
 	}
+	// -> metrics
+	registry := metrics.NewRegistry()
+	// -> tracer (nil if disabled, the file is closed once the server is stopped)
+	var tracer *trace.Tracer
+	onErrorTrace := func(err error) {
+		logger.Warn("trace export failed", slog.String("error", err.Error()))
+	}
+	switch a.cfg.Trace.Exporter {
+	case TraceExporterStderr:
+		tracer = trace.NewTracer(trace.NewExporterWriter(os.Stderr), onErrorTrace)
+	case TraceExporterFile:
+		var f *os.File
+		f, err = os.OpenFile(a.cfg.Trace.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			err = fmt.Errorf("%w. %s", ErrApplicationInternal, err.Error())
+			return
+		}
+		defer f.Close()
+		tracer = trace.NewTracer(trace.NewExporterWriter(f), onErrorTrace)
+	}
+	// -> database (closed once the server is stopped)
+	var db *sql.DB
+	defer func() {
+		if db == nil {
+			return
+		}
+		if errClose := db.Close(); errClose != nil && err == nil {
+			err = fmt.Errorf("%w. %s", ErrApplicationInternal, errClose.Error())
+		}
+	}()
 
 	// -> products
-	stProducts := storage.NewImplStorageProductMySQL(db)
-	ctProducts := handlers.NewControllerProduct(stProducts)
+	var stProducts storage.StorageProduct
+	switch a.cfg.Storage {
+	case StorageMap:
+		stProducts = storage.NewImplStorageProductMap(nil)
+	case StorageMySQL:
+		// -> database (pinged until it answers)
+		db, err = database.Open(ctx, &a.cfg.Db.Config)
+		if err != nil {
+			err = fmt.Errorf("%w. %s", ErrApplicationInternal, err.Error())
+			return
+		}
+
+		// -> migrations
+		if a.cfg.Db.Migrate {
+			var ms []*migrate.Migration
+			ms, err = migrate.Load(migrations.FS)
+			if err != nil {
+				err = fmt.Errorf("%w. %s", ErrApplicationInternal, err.Error())
+				return
+			}
+			_, err = migrate.NewMigrator(db, ms).Up(ctx)
+			if err != nil {
+				err = fmt.Errorf("%w. %s", ErrApplicationInternal, err.Error())
+				return
+			}
+		}
+
+		database.RegisterMetrics(registry, db)
+
+		stProducts = storage.NewImplStorageProductMySQL(db, &storage.ConfigStorageProductMySQL{QueryTimeout: a.cfg.Db.QueryTimeout, Logger: logger, Tracer: tracer})
+	default:
+		err = fmt.Errorf("%w. unknown storage %q", ErrApplicationInternal, a.cfg.Storage)
+		return
+	}
+	stProducts = storage.NewImplStorageProductMetrics(stProducts, registry)
+	vlProducts := validator.NewImplValidatorProduct(&validator.ConfigValidatorProduct{Types: a.cfg.ProductTypes})
+	svProducts := service.NewImplServiceProduct(stProducts, vlProducts)
+	ctProducts := handlers.NewControllerProduct(svProducts, logger, tracer)
+	// -> health
+	ctHealth := handlers.NewControllerHealth(&handlers.ConfigControllerHealth{Logger: logger})
+	if db != nil {
+		ctHealth.Register("database", db.PingContext)
+	}
+	// -> admin
+	var ctAdmin *handlers.ControllerAdmin
+	if db != nil {
+		ctAdmin = handlers.NewControllerAdmin(db.Stats)
+	}
+	// -> authentication (a pass-through if disabled)
+	auth := func(next http.Handler) http.Handler { return next }
+	if a.cfg.Auth.Enabled {
+		auth, err = a.cfg.Auth.Auth(logger)
+		if err != nil {
+			err = fmt.Errorf("%w. %s", ErrApplicationInternal, err.Error())
+			return
+		}
+	} else {
+		logger.Warn("authentication disabled, the products and admin routes are public")
+	}
 
 	// -> server
 	r := chi.NewRouter()
 
+	// middlewares
+	// -> recovery is innermost, so the access log and the request id cover the 500 it writes
+	// -> the trace wraps the others, so their logs have the trace id
+	r.Use(middlewares.RequestID)
+	r.Use(middlewares.Trace(tracer))
+	r.Use(middlewares.Metrics(registry))
+	r.Use(middlewares.AccessLog(logger))
+	r.Use(middlewares.Recover(logger))
+
 	// routes
-	// -> products
-	r.Get("/products/{id}", ctProducts.GetOne())
-	r.Post("/products", ctProducts.Store())
-	r.Put("/products/{id}", ctProducts.Update())
-	r.Delete("/products/{id}", ctProducts.Delete())
+	// -> health
+	r.Get("/healthz", ctHealth.Healthz())
+	r.Get("/readyz", ctHealth.Readyz())
+	r.Get("/version", ctHealth.Version())
+	// -> metrics
+	r.Get("/metrics", registry.Handler())
+	// -> authenticated
+	r.Group(func(r chi.Router) {
+		r.Use(auth)
+
+		// -> admin
+		if ctAdmin != nil {
+			r.Get("/admin/db/stats", ctAdmin.DBStats())
+		}
+		// -> products
+		r.Get("/products", ctProducts.GetAll())
+		r.Get("/products/export", ctProducts.Export())
+		r.Get("/products/{id}", ctProducts.GetOne())
+		r.Post("/products", ctProducts.Store())
+		r.Post("/products/batch", ctProducts.Batch())
+		r.Post("/products/import", ctProducts.Import())
+		r.Put("/products/{id}", ctProducts.Update())
+		r.Patch("/products/{id}", ctProducts.Patch())
+		r.Delete("/products/{id}", ctProducts.Delete())
+	})
 
 	// run
-	err = http.ListenAndServe(a.cfg.Server.Addr(), r)
-	if err != nil {
+	srv := &http.Server{
+		Addr: a.cfg.Server.Addr(),
+		Handler: r,
+		ReadHeaderTimeout: a.cfg.Server.ReadHeaderTimeout,
+		ReadTimeout: a.cfg.Server.ReadTimeout,
+		WriteTimeout: a.cfg.Server.WriteTimeout,
+		IdleTimeout: a.cfg.Server.IdleTimeout,
+		ErrorLog: slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+	}
+	logger.Info("server listening", slog.String("addr", srv.Addr), slog.String("storage", a.cfg.Storage))
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err = <-errs:
+		// the server could not listen
 		err = fmt.Errorf("%w. %s", ErrApplicationInternal, err.Error())
 		return
+	case <-ctx.Done():
+		// restore the default behavior, a second signal kills the process
+		stop()
+	}
+
+	// shutdown
+	logger.Info("server shutting down", slog.Duration("drain_delay", a.cfg.Server.DrainDelay), slog.Duration("timeout", a.cfg.Server.ShutdownTimeout))
+	err = shutdown(srv, ctHealth, a.cfg.Server)
+	if err != nil {
+		return
+	}
+	logger.Info("server stopped")
+
+	return
+}
+
+// shutdown stops the server gracefully
+// - the readiness fails for DrainDelay while the server still serves, so the orchestrator sees it
+//   and stops routing new traffic before the server stops accepting requests
+// - then the in-flight requests are drained for at most ShutdownTimeout, the remaining ones are dropped
+func shutdown(srv *http.Server, health *handlers.ControllerHealth, cfg *ConfigServer) (err error) {
+	health.Drain()
+	time.Sleep(cfg.DrainDelay)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if cfg.ShutdownTimeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	}
+	defer cancel()
+	err = srv.Shutdown(ctx)
+	if err != nil {
+		// the deadline passed, drop the remaining connections
+		srv.Close()
+		err = fmt.Errorf("%w. shutdown: %s", ErrApplicationInternal, err.Error())
+		return
 	}
-	
 	return
 }
\ No newline at end of file
@@ -0,0 +1,50 @@
+package dependencies
+
+import (
+	"app/cmd/server/handlers"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Tests for shutdown function
+func TestShutdown(t *testing.T) {
+	// arrange
+	health := handlers.NewControllerHealth(nil)
+	mux := http.NewServeMux()
+	mux.Handle("/readyz", health.Readyz())
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := &http.Server{Handler: mux}
+	go srv.Serve(ln)
+	url := "http://" + ln.Addr().String() + "/readyz"
+
+	res, err := http.Get(url)
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	// act
+	errs := make(chan error, 1)
+	go func() {
+		errs <- shutdown(srv, health, &ConfigServer{DrainDelay: 500 * time.Millisecond, ShutdownTimeout: time.Second})
+	}()
+
+	// assert
+	// -> the server still serves during the drain delay, with the readiness failing
+	require.Eventually(t, func() bool {
+		res, err := http.Get(url)
+		if err != nil {
+			return false
+		}
+		res.Body.Close()
+		return res.StatusCode == http.StatusServiceUnavailable
+	}, 400*time.Millisecond, 10*time.Millisecond)
+	require.NoError(t, <-errs)
+	// -> then it is stopped
+	_, err = http.Get(url)
+	require.Error(t, err)
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"app/pkg/web/response"
+	"database/sql"
+	"net/http"
+)
+
+// NewControllerAdmin returns new ControllerAdmin
+// - stats returns the statistics of the database pool, usually (*sql.DB).Stats
+func NewControllerAdmin(stats func() sql.DBStats) *ControllerAdmin {
+	return &ControllerAdmin{stats: stats}
+}
+
+// ControllerAdmin is a controller for the operators of the server
+type ControllerAdmin struct {
+	// stats returns the statistics of the database pool
+	stats func() sql.DBStats
+}
+
+// ResponseDBStats is the representation of the statistics of the database pool
+type ResponseDBStats struct {
+	// pool
+	MaxOpenConnections	int		`json:"max_open_connections"`
+	OpenConnections		int		`json:"open_connections"`
+	InUse				int		`json:"in_use"`
+	Idle				int		`json:"idle"`
+	// waits for a connection
+	WaitCount			int64	`json:"wait_count"`
+	WaitDurationMs		int64	`json:"wait_duration_ms"`
+	// closed connections
+	MaxIdleClosed		int64	`json:"max_idle_closed"`
+	MaxIdleTimeClosed	int64	`json:"max_idle_time_closed"`
+	MaxLifetimeClosed	int64	`json:"max_lifetime_closed"`
+}
+
+// ResponseBodyDBStats is the response body of the statistics of the database pool
+type ResponseBodyDBStats struct {
+	Message string				`json:"message"`
+	Data    *ResponseDBStats	`json:"data"`
+	Error   bool				`json:"error"`
+}
+
+// DBStats returns the statistics of the database pool
+func (c *ControllerAdmin) DBStats() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// process
+		stats := c.stats()
+
+		// response
+		code := http.StatusOK
+		body := &ResponseBodyDBStats{Message: "success", Data: &ResponseDBStats{
+			MaxOpenConnections:	stats.MaxOpenConnections,
+			OpenConnections:	stats.OpenConnections,
+			InUse:				stats.InUse,
+			Idle:				stats.Idle,
+			WaitCount:			stats.WaitCount,
+			WaitDurationMs:		stats.WaitDuration.Milliseconds(),
+			MaxIdleClosed:		stats.MaxIdleClosed,
+			MaxIdleTimeClosed:	stats.MaxIdleTimeClosed,
+			MaxLifetimeClosed:	stats.MaxLifetimeClosed,
+		}, Error: false}
+
+		response.JSON(w, code, body)
+	}
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Tests for ControllerAdmin.DBStats handler
+func TestControllerAdmin_DBStats(t *testing.T) {
+	// arrange
+	ct := NewControllerAdmin(func() sql.DBStats {
+		return sql.DBStats{MaxOpenConnections: 25, OpenConnections: 3, InUse: 1, Idle: 2, WaitCount: 4, WaitDuration: 1500 * time.Millisecond, MaxLifetimeClosed: 7}
+	})
+	req := httptest.NewRequest(http.MethodGet, "/admin/db/stats", nil)
+	res := httptest.NewRecorder()
+
+	// act
+	ct.DBStats()(res, req)
+
+	// assert
+	require.Equal(t, http.StatusOK, res.Code)
+	require.JSONEq(t, `{"message":"success","data":{
+		"max_open_connections":25,"open_connections":3,"in_use":1,"idle":2,
+		"wait_count":4,"wait_duration_ms":1500,
+		"max_idle_closed":0,"max_idle_time_closed":0,"max_lifetime_closed":7
+	},"error":false}`, res.Body.String())
+}
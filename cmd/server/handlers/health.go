@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"app/pkg/web/response"
+	"context"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Check reports whether a dependency of the server is ready, returning why it is not
+type Check func(ctx context.Context) (err error)
+
+// ConfigControllerHealth is the configuration of ControllerHealth
+type ConfigControllerHealth struct {
+	// CheckTimeout is the max duration of each check (2s by default)
+	CheckTimeout time.Duration
+	// Logger logs the failed checks (slog.Default() by default)
+	Logger *slog.Logger
+}
+
+// NewControllerHealth returns new ControllerHealth
+func NewControllerHealth(cfg *ConfigControllerHealth) *ControllerHealth {
+	// default config
+	defaultCfg := &ConfigControllerHealth{
+		CheckTimeout: 2 * time.Second,
+		Logger: slog.Default(),
+	}
+	if cfg != nil {
+		if cfg.CheckTimeout > 0 {
+			defaultCfg.CheckTimeout = cfg.CheckTimeout
+		}
+		if cfg.Logger != nil {
+			defaultCfg.Logger = cfg.Logger
+		}
+	}
+
+	return &ControllerHealth{cfg: defaultCfg}
+}
+
+// ControllerHealth is a controller for the probes of the orchestrator
+// - liveness: the process is up
+// - readiness: every registered check passes and the server is not draining
+type ControllerHealth struct {
+	// cfg is the configuration
+	cfg *ConfigControllerHealth
+	// mu guards names and checks
+	mu sync.RWMutex
+	// names are the names of the checks, in registration order
+	names []string
+	// checks are the registered checks by name
+	checks map[string]Check
+	// draining is set once the server started a graceful shutdown
+	draining atomic.Bool
+}
+
+// Register adds a check to the readiness, replacing the check with the same name
+func (c *ControllerHealth) Register(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.checks == nil {
+		c.checks = make(map[string]Check)
+	}
+	if _, ok := c.checks[name]; !ok {
+		c.names = append(c.names, name)
+	}
+	c.checks[name] = check
+}
+
+// Drain makes the readiness fail from now on, so no new traffic is routed to the server while it drains
+func (c *ControllerHealth) Drain() {
+	c.draining.Store(true)
+}
+
+// Healthz reports the process is up
+func (c *ControllerHealth) Healthz() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response.Text(w, http.StatusOK, "ok")
+	}
+}
+
+// ResponseBodyReady is the response body of the readiness
+type ResponseBodyReady struct {
+	Message string				`json:"message"`
+	Data    map[string]string	`json:"data"`
+	Error   bool				`json:"error"`
+}
+
+// Readyz reports whether the server can take traffic
+// - data has the result of each check: "ok" or "unavailable" (the reason is only logged, as it may have
+//   the address, user or driver details of the dependency and the endpoint is public)
+// - 503 Service Unavailable if a check fails or the server is draining
+func (c *ControllerHealth) Readyz() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// process
+		// -> draining
+		if c.draining.Load() {
+			code := http.StatusServiceUnavailable
+			body := &ResponseBodyReady{Message: "draining", Data: nil, Error: true}
+
+			response.JSON(w, code, body)
+			return
+		}
+
+		// -> checks
+		c.mu.RLock()
+		names := append([]string(nil), c.names...)
+		checks := make([]Check, len(names))
+		for i, name := range names {
+			checks[i] = c.checks[name]
+		}
+		c.mu.RUnlock()
+
+		results := make(map[string]string, len(names))
+		ready := true
+		for i, name := range names {
+			ctx, cancel := context.WithTimeout(r.Context(), c.cfg.CheckTimeout)
+			err := checks[i](ctx)
+			cancel()
+
+			results[name] = "ok"
+			if err != nil {
+				c.cfg.Logger.WarnContext(r.Context(), "readiness check failed", slog.String("check", name), slog.String("error", err.Error()))
+				results[name] = "unavailable"
+				ready = false
+			}
+		}
+
+		// response
+		if !ready {
+			code := http.StatusServiceUnavailable
+			body := &ResponseBodyReady{Message: "not ready", Data: results, Error: true}
+
+			response.JSON(w, code, body)
+			return
+		}
+
+		code := http.StatusOK
+		body := &ResponseBodyReady{Message: "ready", Data: results, Error: false}
+
+		response.JSON(w, code, body)
+	}
+}
+
+// ResponseVersion is the build information of the server
+type ResponseVersion struct {
+	// Path is the path of the main package
+	Path		string	`json:"path"`
+	// Version is the version of the main module ("(devel)" when built from a working tree)
+	Version		string	`json:"version"`
+	// GoVersion is the version of the Go toolchain
+	GoVersion	string	`json:"go_version"`
+	// Revision, Time and Modified describe the vcs commit the binary was built from, if known
+	Revision	string	`json:"revision"`
+	Time		string	`json:"time"`
+	Modified	bool	`json:"modified"`
+}
+
+// ResponseBodyVersion is the response body of the version
+type ResponseBodyVersion struct {
+	Message string				`json:"message"`
+	Data    *ResponseVersion	`json:"data"`
+	Error   bool				`json:"error"`
+}
+
+// Version returns the build information of the server
+func (c *ControllerHealth) Version() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// process
+		info, ok := debug.ReadBuildInfo()
+		if !ok {
+			code := http.StatusInternalServerError
+			body := &ResponseBodyVersion{Message: "build info not available", Data: nil, Error: true}
+
+			response.JSON(w, code, body)
+			return
+		}
+
+		version := &ResponseVersion{
+			Path:		info.Path,
+			Version:	info.Main.Version,
+			GoVersion:	info.GoVersion,
+		}
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				version.Revision = s.Value
+			case "vcs.time":
+				version.Time = s.Value
+			case "vcs.modified":
+				version.Modified = s.Value == "true"
+			}
+		}
+
+		// response
+		code := http.StatusOK
+		body := &ResponseBodyVersion{Message: "success", Data: version, Error: false}
+
+		response.JSON(w, code, body)
+	}
+}
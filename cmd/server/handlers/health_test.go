@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Tests for ControllerHealth.Healthz handler
+func TestControllerHealth_Healthz(t *testing.T) {
+	// arrange
+	ct := NewControllerHealth(nil)
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	res := httptest.NewRecorder()
+
+	// act
+	ct.Healthz()(res, req)
+
+	// assert
+	require.Equal(t, http.StatusOK, res.Code)
+	require.Equal(t, "ok", res.Body.String())
+}
+
+// Tests for ControllerHealth.Readyz handler
+func TestControllerHealth_Readyz(t *testing.T) {
+	type input struct { checks map[string]Check; draining bool }
+	type output struct { code int; body string }
+	type testCase struct {
+		name string
+		input input
+		output output
+	}
+
+	ok := func(ctx context.Context) error { return nil }
+	failing := func(ctx context.Context) error { return errors.New("connection refused") }
+	cases := []testCase{
+		// valid cases
+		{
+			name: "200 - no checks",
+			input: input{},
+			output: output{code: http.StatusOK, body: `{"message":"ready","data":{},"error":false}`},
+		},
+		{
+			name: "200 - every check passes",
+			input: input{checks: map[string]Check{"database": ok}},
+			output: output{code: http.StatusOK, body: `{"message":"ready","data":{"database":"ok"},"error":false}`},
+		},
+
+		// invalid cases
+		{
+			name: "503 - a check fails",
+			input: input{checks: map[string]Check{"database": failing, "cache": ok}},
+			output: output{code: http.StatusServiceUnavailable, body: `{"message":"not ready","data":{"cache":"ok","database":"unavailable"},"error":true}`},
+		},
+		{
+			name: "503 - draining",
+			input: input{checks: map[string]Check{"database": ok}, draining: true},
+			output: output{code: http.StatusServiceUnavailable, body: `{"message":"draining","data":null,"error":true}`},
+		},
+	}
+
+	// run tests
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// arrange
+			ct := NewControllerHealth(nil)
+			for name, check := range c.input.checks {
+				ct.Register(name, check)
+			}
+			if c.input.draining {
+				ct.Drain()
+			}
+			req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+			res := httptest.NewRecorder()
+
+			// act
+			ct.Readyz()(res, req)
+
+			// assert
+			require.Equal(t, c.output.code, res.Code)
+			require.JSONEq(t, c.output.body, res.Body.String())
+		})
+	}
+}
+
+// Tests for ControllerHealth.Version handler
+func TestControllerHealth_Version(t *testing.T) {
+	// arrange
+	ct := NewControllerHealth(nil)
+	req := httptest.NewRequest(http.MethodGet, "/version", nil)
+	res := httptest.NewRecorder()
+
+	// act
+	ct.Version()(res, req)
+
+	// assert
+	require.Equal(t, http.StatusOK, res.Code)
+	require.Contains(t, res.Body.String(), `"go_version":"go`)
+}
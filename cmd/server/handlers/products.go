@@ -1,10 +1,18 @@
 package handlers
 
 import (
+	"app/internal/products/service"
 	"app/internal/products/storage"
+	"app/internal/products/validator"
+	"app/pkg/jsonpatch"
+	"app/pkg/trace"
 	"app/pkg/web/request"
 	"app/pkg/web/response"
 	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
 	"net/http"
 	"strconv"
 
@@ -12,30 +20,119 @@ import (
 )
 
 // NewControllerProduct returns new ControllerProduct
-func NewControllerProduct(storage storage.StorageProduct) *ControllerProduct {
-	return &ControllerProduct{storage: storage}
+// - logger can be nil, in that case slog.Default() is used
+// - tracer can be nil, in that case the handlers are not traced
+func NewControllerProduct(service service.ServiceProduct, logger *slog.Logger, tracer *trace.Tracer) *ControllerProduct {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &ControllerProduct{service: service, logger: logger, tracer: tracer}
 }
 
 // ControllerProduct is a controller for products
+// - it only deals with http concerns, the business rules are in the service
 type ControllerProduct struct {
-	// storage is a storage for products
-	storage storage.StorageProduct
+	// service is the service for products
+	service service.ServiceProduct
+	// logger logs the errors behind the internal error responses
+	logger *slog.Logger
+	// tracer starts a span for each handler
+	tracer *trace.Tracer
 }
 
-// GetOne returns one product by id
+// traced returns hd run in a span named after the handler, child of the span of the request
+func (c *ControllerProduct) traced(name string, hd http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := c.tracer.Start(r.Context(), "ControllerProduct."+name)
+		defer span.End()
+
+		hd(w, r.WithContext(ctx))
+	}
+}
+
+// logError logs the error behind an internal error response, and records it in the span of the handler
+// - the client only gets "internal error", the log has the whole error with the request of r
+func (c *ControllerProduct) logError(r *http.Request, err error) {
+	c.logger.ErrorContext(r.Context(), "internal error",
+		slog.String("method", r.Method),
+		slog.String("error", err.Error()),
+	)
+	trace.SpanFromContext(r.Context()).RecordError(err)
+}
+
+// ResponseBodyValidation is the response body of a product that failed validation
+type ResponseBodyValidation struct {
+	Message string					`json:"message"`
+	Data    []validator.FieldError	`json:"data"`
+	Error   bool					`json:"error"`
+}
+
+// responseValidation writes the response of a validation error
+// - an *ErrorValidatorProduct lists every failing field with 422 Unprocessable Entity
+func (c *ControllerProduct) responseValidation(w http.ResponseWriter, r *http.Request, err error) {
+	var errValidation *validator.ErrorValidatorProduct
+	if !errors.As(err, &errValidation) {
+		c.logError(r, err)
+		code := http.StatusInternalServerError
+		body := &ResponseBody{Message: "internal error", Data: nil, Error: true}
+
+		response.JSON(w, code, body)
+		return
+	}
+
+	code := http.StatusUnprocessableEntity
+	body := &ResponseBodyValidation{Message: "invalid product", Data: errValidation.Fields, Error: true}
+
+	response.JSON(w, code, body)
+}
+
+// etagProduct returns the entity tag of the product (its version)
+func etagProduct(p *storage.Product) string {
+	return fmt.Sprintf(`"%d"`, p.Version)
+}
+
+// preconditionIfMatch returns the precondition of the If-Match header of the request (nil without the header)
+func preconditionIfMatch(r *http.Request) service.Precondition {
+	if r.Header.Get("If-Match") == "" {
+		return nil
+	}
+
+	return func(current *storage.Product) bool {
+		match, _ := request.IfMatch(r, etagProduct(current))
+		return match
+	}
+}
+
+// ResponseProduct is the representation of a product shared by every product response
 type ResponseProduct struct {
+	ID		int		`json:"id"`
 	Name    string	`json:"name"`
-	Type	string	`json:"type"`
-	Count	int		`json:"count"`
-	Price	float64	`json:"price"`
+	Type	*string	`json:"type"`
+	Count	*int	`json:"count"`
+	Price	*float64	`json:"price"`
 }
 type ResponseBody struct {
 	Message string			 `json:"message"`
 	Data    *ResponseProduct `json:"data"`
 	Error   bool			 `json:"error"`
 }
+
+// serializeProduct returns the representation of the product
+func serializeProduct(p *storage.Product) *ResponseProduct {
+	return &ResponseProduct{
+		ID:		p.ID,
+		Name:   p.Name,
+		Type:	p.Type,
+		Count:	p.Count,
+		Price:	p.Price,
+	}
+}
+
+// GetOne returns one product by id
+// - supports conditional requests with If-None-Match and If-Modified-Since (304 Not Modified)
 func (c *ControllerProduct) GetOne() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+	return c.traced("GetOne", func(w http.ResponseWriter, r *http.Request) {
 		// request
 		id, err := strconv.Atoi(chi.URLParam(r, "id"))
 		if err != nil {
@@ -48,14 +145,15 @@ func (c *ControllerProduct) GetOne() http.HandlerFunc {
 
 
 		// process
-		product, err := c.storage.GetOne(id)
+		product, err := c.service.GetOne(r.Context(), id)
 		if err != nil {
 			var code int; var body *ResponseBody
 			switch {
-			case errors.Is(err, storage.ErrStorageProductNotFound):
+			case errors.Is(err, service.ErrServiceProductNotFound):
 				code = http.StatusNotFound
 				body = &ResponseBody{Message: "product not found", Data: nil, Error: true}
 			default:
+				c.logError(r, err)
 				code = http.StatusInternalServerError
 				body = &ResponseBody{Message: "internal error", Data: nil, Error: true}
 			}
@@ -65,42 +163,180 @@ func (c *ControllerProduct) GetOne() http.HandlerFunc {
 		}
 
 		// response
+		w.Header().Set("ETag", etagProduct(product))
+		w.Header().Set("Last-Modified", product.UpdatedAt.UTC().Format(http.TimeFormat))
+		// -> conditional get (If-None-Match takes precedence over If-Modified-Since)
+		notModified, present := request.IfNoneMatch(r, etagProduct(product))
+		if !present {
+			modified, _ := request.IfModifiedSince(r, product.UpdatedAt)
+			notModified = !modified
+		}
+		if notModified {
+			response.JSON(w, http.StatusNotModified, nil)
+			return
+		}
+
 		code := http.StatusOK
 		body := &ResponseBody{
 			Message: "success",
-			Data: &ResponseProduct{			// serialization
-				Name:   product.Name,
-				Type:	product.Type,
-				Count:	product.Count,
-				Price:	product.Price,
+			Data: serializeProduct(product),
+			Error: false,
+		}
+
+		response.JSON(w, code, body)
+	})
+}
+
+// GetAll returns a page of products
+const (
+	// DefaultLimitGetAll is the page size used when limit is not specified
+	DefaultLimitGetAll = 10
+	// MaxLimitGetAll is the maximum page size allowed
+	MaxLimitGetAll = 100
+)
+type ResponseProductGetAll struct {
+	Items	[]*ResponseProduct	`json:"items"`
+	Total	int					`json:"total"`
+	Limit	int					`json:"limit"`
+	Offset	int					`json:"offset"`
+}
+type ResponseBodyGetAll struct {
+	Message string					`json:"message"`
+	Data    *ResponseProductGetAll	`json:"data"`
+	Error   bool					`json:"error"`
+}
+func (c *ControllerProduct) GetAll() http.HandlerFunc {
+	return c.traced("GetAll", func(w http.ResponseWriter, r *http.Request) {
+		// request
+		q, err := queryProductGetAll(r)
+		if err != nil {
+			code := http.StatusBadRequest
+			body := &ResponseBody{Message: err.Error(), Data: nil, Error: true}
+
+			response.JSON(w, code, body)
+			return
+		}
+
+		// process
+		products, total, err := c.service.GetAll(r.Context(), q)
+		if err != nil {
+			var code int; var body *ResponseBody
+			switch {
+			case errors.Is(err, service.ErrServiceProductQueryInvalid):
+				code = http.StatusBadRequest
+				body = &ResponseBody{Message: "invalid query", Data: nil, Error: true}
+			default:
+				c.logError(r, err)
+				code = http.StatusInternalServerError
+				body = &ResponseBody{Message: "internal error", Data: nil, Error: true}
+			}
+
+			response.JSON(w, code, body)
+			return
+		}
+
+		// response
+		// -> serialization
+		items := make([]*ResponseProduct, 0, len(products))
+		for _, product := range products {
+			items = append(items, serializeProduct(product))
+		}
+
+		code := http.StatusOK
+		body := &ResponseBodyGetAll{
+			Message: "success",
+			Data: &ResponseProductGetAll{
+				Items:	items,
+				Total:	total,
+				Limit:	q.Limit,
+				Offset:	q.Offset,
 			},
 			Error: false,
 		}
 
 		response.JSON(w, code, body)
+	})
+}
+
+// queryProductGetAll builds the storage query from the query params of the request
+// - pagination: limit, offset
+// - filters: type, min_price, max_price, min_count, max_count, name (substring)
+// - sort: comma separated fields, prefixed with "-" for descending order (example: sort=type,-price)
+func queryProductGetAll(r *http.Request) (q *storage.QueryProduct, err error) {
+	values := r.URL.Query()
+	q = &storage.QueryProduct{Limit: DefaultLimitGetAll}
+
+	// pagination
+	if v := values.Get("limit"); v != "" {
+		q.Limit, err = strconv.Atoi(v)
+		if err != nil || q.Limit < 1 || q.Limit > MaxLimitGetAll {
+			err = fmt.Errorf("limit must be an int between 1 and %d", MaxLimitGetAll)
+			return
+		}
 	}
+	if v := values.Get("offset"); v != "" {
+		q.Offset, err = strconv.Atoi(v)
+		if err != nil || q.Offset < 0 {
+			err = errors.New("offset must be a non-negative int")
+			return
+		}
+	}
+
+	// filters
+	if values.Has("type") {
+		v := values.Get("type")
+		q.Filter.Type = &v
+	}
+	for _, param := range []struct{ key string; ptr **float64 }{
+		{key: "min_price", ptr: &q.Filter.MinPrice},
+		{key: "max_price", ptr: &q.Filter.MaxPrice},
+	} {
+		if v := values.Get(param.key); v != "" {
+			var f float64
+			f, err = strconv.ParseFloat(v, 64)
+			if err != nil {
+				err = fmt.Errorf("%s must be a number", param.key)
+				return
+			}
+			*param.ptr = &f
+		}
+	}
+	for _, param := range []struct{ key string; ptr **int }{
+		{key: "min_count", ptr: &q.Filter.MinCount},
+		{key: "max_count", ptr: &q.Filter.MaxCount},
+	} {
+		if v := values.Get(param.key); v != "" {
+			var i int
+			i, err = strconv.Atoi(v)
+			if err != nil {
+				err = fmt.Errorf("%s must be an int", param.key)
+				return
+			}
+			*param.ptr = &i
+		}
+	}
+	q.Filter.Name = values.Get("name")
+
+	// sort
+	q.Sort, err = storage.ParseSortProduct(values.Get("sort"))
+	if err != nil {
+		err = fmt.Errorf("sort fields must be one of %v", storage.FieldsProduct)
+		return
+	}
+
+	return
 }
 
 // Store stores product
+// - optional fields (type, count, price) missing or null are stored as unknown, 0 is stored as 0
 type RequestProductStore struct {
 	Name    string	`json:"name"`
-	Type	string	`json:"type"`
-	Count	int		`json:"count"`
-	Price	float64	`json:"price"`
-}
-type ResponseProductStore struct {
-	Name    string	`json:"name"`
-	Type	string	`json:"type"`
-	Count	int		`json:"count"`
-	Price	float64	`json:"price"`
-}
-type ResponseBodyStore struct {
-	Message string					`json:"message"`
-	Data    *ResponseProductStore	`json:"data"`
-	Error   bool					`json:"error"`
+	Type	*string	`json:"type"`
+	Count	*int	`json:"count"`
+	Price	*float64	`json:"price"`
 }
 func (c *ControllerProduct) Store() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+	return c.traced("Store", func(w http.ResponseWriter, r *http.Request) {
 		// request
 		var req RequestProductStore
 		err := request.JSON(r, &req)
@@ -120,14 +356,19 @@ func (c *ControllerProduct) Store() http.HandlerFunc {
 			Count:	req.Count,
 			Price:	req.Price,
 		}
-		err = c.storage.Store(product)
+		// -> store product
+		err = c.service.Store(r.Context(), product)
 		if err != nil {
 			var code int; var body *ResponseBody
 			switch {
-			case errors.Is(err, storage.ErrStorageProductNotUnique):
+			case errors.Is(err, validator.ErrValidatorProductInvalid):
+				c.responseValidation(w, r, err)
+				return
+			case errors.Is(err, service.ErrServiceProductNotUnique):
 				code = http.StatusBadRequest
 				body = &ResponseBody{Message: "product not unique", Data: nil, Error: true}
 			default:
+				c.logError(r, err)
 				code = http.StatusInternalServerError
 				body = &ResponseBody{Message: "internal error", Data: nil, Error: true}
 			}
@@ -137,42 +378,30 @@ func (c *ControllerProduct) Store() http.HandlerFunc {
 		}
 
 		// response
+		w.Header().Set("ETag", etagProduct(product))
+		w.Header().Set("Last-Modified", product.UpdatedAt.UTC().Format(http.TimeFormat))
+		w.Header().Set("Location", fmt.Sprintf("/products/%d", product.ID))
 		code := http.StatusCreated
-		body := &ResponseBodyStore{
+		body := &ResponseBody{
 			Message: "success",
-			Data: &ResponseProductStore{	// serialization
-				Name:   product.Name,
-				Type:	product.Type,
-				Count:	product.Count,
-				Price:	product.Price,
-			},
+			Data: serializeProduct(product),
 			Error: false,
 		}
 
 		response.JSON(w, code, body)
-	}
+	})
 }
 
-// Update updates product
+// Update replaces product
+// - every field is required in the body, optional fields (type, count, price) can be null
 type RequestProductUpdate struct {
 	Name    string	`json:"name"`
-	Type	string	`json:"type"`
-	Count	int		`json:"count"`
-	Price	float64	`json:"price"`
-}
-type ResponseProductUpdate struct {
-	Name    string	`json:"name"`
-	Type	string	`json:"type"`
-	Count	int		`json:"count"`
-	Price	float64	`json:"price"`
-}
-type ResponseBodyUpdate struct {
-	Message string					`json:"message"`
-	Data    *ResponseProductUpdate	`json:"data"`
-	Error   bool					`json:"error"`
+	Type	*string	`json:"type"`
+	Count	*int	`json:"count"`
+	Price	*float64	`json:"price"`
 }
 func (c *ControllerProduct) Update() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+	return c.traced("Update", func(w http.ResponseWriter, r *http.Request) {
 		// request
 		id, err := strconv.Atoi(chi.URLParam(r, "id"))
 		if err != nil {
@@ -183,83 +412,140 @@ func (c *ControllerProduct) Update() http.HandlerFunc {
 			return
 		}
 
-		// process
-		// -> get searched product by id
-		pr, err := c.storage.GetOne(id)
+		var req RequestProductUpdate
+		fields, err := request.JSONFields(r, &req)
 		if err != nil {
-			var code int; var body *ResponseBodyUpdate
-			switch {
-			case errors.Is(err, storage.ErrStorageProductNotFound):
-				code = http.StatusNotFound
-				body = &ResponseBodyUpdate{Message: "product not found", Data: nil, Error: true}
-			default:
-				code = http.StatusInternalServerError
-				body = &ResponseBodyUpdate{Message: "internal error", Data: nil, Error: true}
-			}
+			code := http.StatusBadRequest
+			body := &ResponseBody{Message: "invalid json", Data: nil, Error: true}
 
 			response.JSON(w, code, body)
 			return
 		}
-		// -- serialization
-		product := &RequestProductUpdate{
-			Name:   pr.Name,
-			Type:	pr.Type,
-			Count:	pr.Count,
-			Price:	pr.Price,
+		// -> every field is required on a full replacement
+		var missing []validator.FieldError
+		for _, field := range []string{"name", "type", "count", "price"} {
+			if !fields[field] {
+				missing = append(missing, validator.FieldError{Field: field, Message: "is required"})
+			}
+		}
+		if len(missing) > 0 {
+			c.responseValidation(w, r, &validator.ErrorValidatorProduct{Fields: missing})
+			return
+		}
+
+		// process
+		// -> deserialization
+		prUpdate := &storage.Product{
+			ID:		id,
+			Name:   req.Name,
+			Type:	req.Type,
+			Count:	req.Count,
+			Price:	req.Price,
 		}
+		// -> update product (only if it matches the If-Match header)
+		err = c.service.Update(r.Context(), prUpdate, preconditionIfMatch(r))
+
+		// response
+		c.responseUpdate(w, r, prUpdate, err)
+	})
+}
 
-		// -> patch product to RequestProductUpdate(filled with original data)
-		err = request.JSON(r, product)
+// Patch partially updates product
+// - Content-Type application/merge-patch+json: RFC 7396 JSON Merge Patch (null clears a field)
+// - Content-Type application/json-patch+json: RFC 6902 JSON Patch
+func (c *ControllerProduct) Patch() http.HandlerFunc {
+	return c.traced("Patch", func(w http.ResponseWriter, r *http.Request) {
+		// request
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
 		if err != nil {
 			code := http.StatusBadRequest
-			body := &ResponseBody{Message: "invalid json", Data: nil, Error: true}
+			body := &ResponseBody{Message: "parameter must be int", Data: nil, Error: true}
 
 			response.JSON(w, code, body)
 			return
 		}
-		// -- deserialization
-		prUpdate := &storage.Product{
-			ID:		id,
-			Name:   product.Name,
-			Type:	product.Type,
-			Count:	product.Count,
-			Price:	product.Price,
+
+		var format service.PatchFormat
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mediaType {
+		case jsonpatch.ContentTypeMergePatch:
+			format = service.PatchFormatMerge
+		case jsonpatch.ContentTypeJSONPatch:
+			format = service.PatchFormatJSON
+		default:
+			code := http.StatusUnsupportedMediaType
+			body := &ResponseBody{Message: "content type must be " + jsonpatch.ContentTypeMergePatch + " or " + jsonpatch.ContentTypeJSONPatch, Data: nil, Error: true}
+
+			w.Header().Set("Accept-Patch", jsonpatch.ContentTypeMergePatch+", "+jsonpatch.ContentTypeJSONPatch)
+			response.JSON(w, code, body)
+			return
 		}
-		// -- update product
-		err = c.storage.Update(prUpdate)
+
+		bodyPatch, err := io.ReadAll(r.Body)
 		if err != nil {
-			var code int; var body *ResponseBody
-			switch {
-			case errors.Is(err, storage.ErrStorageProductNotFound):
-				code = http.StatusNotFound
-				body = &ResponseBody{Message: "product not found", Data: nil, Error: true}
-			case errors.Is(err, storage.ErrStorageProductNotUnique):
-				code = http.StatusBadRequest
-				body = &ResponseBody{Message: "product not unique", Data: nil, Error: true}
-			default:
-				code = http.StatusInternalServerError
-				body = &ResponseBody{Message: "internal error", Data: nil, Error: true}
-			}
+			code := http.StatusBadRequest
+			body := &ResponseBody{Message: "invalid body", Data: nil, Error: true}
 
 			response.JSON(w, code, body)
 			return
 		}
 
+		// process
+		// -> patch product (only if it matches the If-Match header)
+		prUpdate, err := c.service.Patch(r.Context(), id, format, bodyPatch, preconditionIfMatch(r))
+
 		// response
-		code := http.StatusOK
-		body := &ResponseBodyUpdate{
-			Message: "success",
-			Data: &ResponseProductUpdate{	// serialization
-				Name:   prUpdate.Name,
-				Type:	prUpdate.Type,
-				Count:	prUpdate.Count,
-				Price:	prUpdate.Price,
-			},
-			Error: false,
+		c.responseUpdate(w, r, prUpdate, err)
+	})
+}
+
+// responseUpdate writes the response of an update of the product that returned err
+func (c *ControllerProduct) responseUpdate(w http.ResponseWriter, r *http.Request, prUpdate *storage.Product, err error) {
+	if err != nil {
+		var code int; var body *ResponseBody
+		switch {
+		case errors.Is(err, validator.ErrValidatorProductInvalid):
+			c.responseValidation(w, r, err)
+			return
+		case errors.Is(err, service.ErrServiceProductPreconditionFailed):
+			code = http.StatusPreconditionFailed
+			body = &ResponseBody{Message: "precondition failed", Data: nil, Error: true}
+		case errors.Is(err, service.ErrServiceProductNotFound):
+			code = http.StatusNotFound
+			body = &ResponseBody{Message: "product not found", Data: nil, Error: true}
+		case errors.Is(err, service.ErrServiceProductNotUnique):
+			code = http.StatusBadRequest
+			body = &ResponseBody{Message: "product not unique", Data: nil, Error: true}
+		case errors.Is(err, service.ErrServiceProductVersionMismatch):
+			code = http.StatusConflict
+			body = &ResponseBody{Message: "product modified concurrently", Data: nil, Error: true}
+		case errors.Is(err, service.ErrServiceProductPatchConflict):
+			code = http.StatusConflict
+			body = &ResponseBody{Message: "patch can not be applied", Data: nil, Error: true}
+		case errors.Is(err, service.ErrServiceProductPatchInvalid):
+			code = http.StatusBadRequest
+			body = &ResponseBody{Message: "invalid patch", Data: nil, Error: true}
+		default:
+			c.logError(r, err)
+			code = http.StatusInternalServerError
+			body = &ResponseBody{Message: "internal error", Data: nil, Error: true}
 		}
 
 		response.JSON(w, code, body)
+		return
 	}
+
+	// response
+	w.Header().Set("ETag", etagProduct(prUpdate))
+	w.Header().Set("Last-Modified", prUpdate.UpdatedAt.UTC().Format(http.TimeFormat))
+	code := http.StatusOK
+	body := &ResponseBody{
+		Message: "success",
+		Data: serializeProduct(prUpdate),
+		Error: false,
+	}
+
+	response.JSON(w, code, body)
 }
 
 // Delete deletes product by id
@@ -269,7 +555,7 @@ type ResponseBodyDelete struct {
 	Error   bool	`json:"error"`
 }
 func (c *ControllerProduct) Delete() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+	return c.traced("Delete", func(w http.ResponseWriter, r *http.Request) {
 		// request
 		id, err := strconv.Atoi(chi.URLParam(r, "id"))
 		if err != nil {
@@ -281,15 +567,19 @@ func (c *ControllerProduct) Delete() http.HandlerFunc {
 		}
 
 		// process
-		// -> delete product by id
-		err = c.storage.Delete(id)
+		// -> delete product by id (only if it matches the If-Match header)
+		err = c.service.Delete(r.Context(), id, preconditionIfMatch(r))
 		if err != nil {
 			var code int; var body *ResponseBody
 			switch {
-			case errors.Is(err, storage.ErrStorageProductNotFound):
+			case errors.Is(err, service.ErrServiceProductPreconditionFailed):
+				code = http.StatusPreconditionFailed
+				body = &ResponseBody{Message: "precondition failed", Data: nil, Error: true}
+			case errors.Is(err, service.ErrServiceProductNotFound):
 				code = http.StatusNotFound
 				body = &ResponseBody{Message: "product not found", Data: nil, Error: true}
 			default:
+				c.logError(r, err)
 				code = http.StatusInternalServerError
 				body = &ResponseBody{Message: "internal error", Data: nil, Error: true}
 			}
@@ -307,5 +597,220 @@ func (c *ControllerProduct) Delete() http.HandlerFunc {
 		}
 
 		response.JSON(w, code, body)
-	}
-}
\ No newline at end of file
+	})
+}
+// Batch creates, updates or deletes many products at once, in one transaction
+// - operation: "create", "update" (full replacement, missing optional fields are cleared) or "delete" (only id is used)
+// - mode: "atomic" (default, every item is applied or none) or "best_effort" (every valid item is applied)
+// - each item reports its own status, the response status is 200 if every item was applied,
+//   207 if some items failed in best effort mode, or the status of the first failure if no item was applied
+const (
+	// MaxItemsBatch is the max amount of items of a batch
+	MaxItemsBatch = 10000
+)
+type RequestProductBatchItem struct {
+	ID		int		`json:"id"`
+	Name    string	`json:"name"`
+	Type	*string	`json:"type"`
+	Count	*int	`json:"count"`
+	Price	*float64	`json:"price"`
+	Version	int		`json:"version"`
+}
+type RequestProductBatch struct {
+	Operation	string						`json:"operation"`
+	Mode		string						`json:"mode"`
+	Items		[]*RequestProductBatchItem	`json:"items"`
+}
+type ResponseProductBatchItem struct {
+	Index	int						`json:"index"`
+	Status	int						`json:"status"`
+	Message	string					`json:"message"`
+	Data	*ResponseProduct		`json:"data"`
+	Errors	[]validator.FieldError	`json:"errors,omitempty"`
+}
+type ResponseProductBatch struct {
+	Operation	string						`json:"operation"`
+	Mode		string						`json:"mode"`
+	Items		[]*ResponseProductBatchItem	`json:"items"`
+}
+type ResponseBodyBatch struct {
+	Message string					`json:"message"`
+	Data    *ResponseProductBatch	`json:"data"`
+	Error   bool					`json:"error"`
+}
+func (c *ControllerProduct) Batch() http.HandlerFunc {
+	return c.traced("Batch", func(w http.ResponseWriter, r *http.Request) {
+		// request
+		var req RequestProductBatch
+		err := request.JSON(r, &req)
+		if err != nil {
+			code := http.StatusBadRequest
+			body := &ResponseBody{Message: "invalid json", Data: nil, Error: true}
+
+			response.JSON(w, code, body)
+			return
+		}
+		var mode storage.BatchMode
+		switch req.Mode {
+		case "", "atomic":
+			req.Mode = "atomic"
+			mode = storage.BatchModeAtomic
+		case "best_effort":
+			mode = storage.BatchModeBestEffort
+		default:
+			code := http.StatusBadRequest
+			body := &ResponseBody{Message: "mode must be atomic or best_effort", Data: nil, Error: true}
+
+			response.JSON(w, code, body)
+			return
+		}
+		if req.Operation != "create" && req.Operation != "update" && req.Operation != "delete" {
+			code := http.StatusBadRequest
+			body := &ResponseBody{Message: "operation must be create, update or delete", Data: nil, Error: true}
+
+			response.JSON(w, code, body)
+			return
+		}
+		if len(req.Items) == 0 || len(req.Items) > MaxItemsBatch {
+			code := http.StatusBadRequest
+			body := &ResponseBody{Message: fmt.Sprintf("items must have between 1 and %d items", MaxItemsBatch), Data: nil, Error: true}
+
+			response.JSON(w, code, body)
+			return
+		}
+
+		// process
+		items := make([]*ResponseProductBatchItem, len(req.Items))
+		// -> deserialization
+		var indexes []int
+		var products []*storage.Product
+		var ids []int
+		for i, item := range req.Items {
+			items[i] = &ResponseProductBatchItem{Index: i}
+			if item == nil {
+				items[i].Status = http.StatusBadRequest
+				items[i].Message = "item must be an object"
+				continue
+			}
+			indexes = append(indexes, i)
+
+			if req.Operation == "delete" {
+				ids = append(ids, item.ID)
+				continue
+			}
+
+			product := &storage.Product{
+				Name:   item.Name,
+				Type:	item.Type,
+				Count:	item.Count,
+				Price:	item.Price,
+			}
+			if req.Operation == "update" {
+				product.ID = item.ID
+				product.Version = item.Version
+			}
+			products = append(products, product)
+		}
+		// -> an atomic batch with malformed items is not applied
+		if mode == storage.BatchModeAtomic && len(indexes) < len(req.Items) {
+			for _, i := range indexes {
+				items[i].Status = http.StatusFailedDependency
+				items[i].Message = "not applied, another item failed"
+			}
+			indexes = nil
+		}
+		// -> apply
+		var errs []error
+		switch {
+		case len(indexes) == 0:
+		case req.Operation == "create":
+			errs, err = c.service.StoreMany(r.Context(), products, mode)
+		case req.Operation == "update":
+			errs, err = c.service.UpdateMany(r.Context(), products, mode)
+		case req.Operation == "delete":
+			errs, err = c.service.DeleteMany(r.Context(), ids, mode)
+		}
+		if err != nil && len(errs) != len(indexes) {
+			c.logError(r, err)
+			code := http.StatusInternalServerError
+			body := &ResponseBody{Message: "internal error", Data: nil, Error: true}
+
+			response.JSON(w, code, body)
+			return
+		}
+		for j, i := range indexes {
+			var errValidation *validator.ErrorValidatorProduct
+			switch {
+			case errs[j] == nil && req.Operation == "create":
+				items[i].Status = http.StatusCreated
+				items[i].Message = "created"
+				items[i].Data = serializeProduct(products[j])
+			case errs[j] == nil && req.Operation == "update":
+				items[i].Status = http.StatusOK
+				items[i].Message = "updated"
+				items[i].Data = serializeProduct(products[j])
+			case errs[j] == nil:
+				items[i].Status = http.StatusOK
+				items[i].Message = "deleted"
+			case errors.As(errs[j], &errValidation):
+				items[i].Status = http.StatusUnprocessableEntity
+				items[i].Message = "invalid product"
+				items[i].Errors = errValidation.Fields
+			case errors.Is(errs[j], service.ErrServiceProductRolledBack):
+				items[i].Status = http.StatusFailedDependency
+				items[i].Message = "not applied, another item failed"
+			case errors.Is(errs[j], service.ErrServiceProductNotFound):
+				items[i].Status = http.StatusNotFound
+				items[i].Message = "product not found"
+			case errors.Is(errs[j], service.ErrServiceProductNotUnique):
+				items[i].Status = http.StatusBadRequest
+				items[i].Message = "product not unique"
+			case errors.Is(errs[j], service.ErrServiceProductVersionMismatch):
+				items[i].Status = http.StatusConflict
+				items[i].Message = "product version mismatch"
+			default:
+				c.logError(r, errs[j])
+				items[i].Status = http.StatusInternalServerError
+				items[i].Message = "internal error"
+			}
+		}
+
+		// response
+		// -> the status of the first failed item if nothing was applied (always the case in atomic mode)
+		// -> 500 if the batch failed as a whole
+		code := http.StatusOK
+		body := &ResponseBodyBatch{
+			Message: "success",
+			Data: &ResponseProductBatch{Operation: req.Operation, Mode: req.Mode, Items: items},
+			Error: false,
+		}
+		var applied int
+		var failed *ResponseProductBatchItem
+		for _, item := range items {
+			switch {
+			case item.Status < 300:
+				applied++
+			case item.Status != http.StatusFailedDependency && failed == nil:
+				failed = item
+			}
+		}
+		switch {
+		case failed == nil && applied == 0 && err != nil:
+			// the batch failed as a whole, no item failed on its own
+			c.logError(r, err)
+			code = http.StatusInternalServerError
+			body.Message = "batch not applied"
+			body.Error = true
+		case failed == nil:
+		case applied == 0:
+			code = failed.Status
+			body.Message = "batch not applied"
+			body.Error = true
+		default:
+			code = http.StatusMultiStatus
+			body.Message = "some items failed"
+		}
+
+		response.JSON(w, code, body)
+	})
+}
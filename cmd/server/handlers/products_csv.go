@@ -0,0 +1,422 @@
+package handlers
+
+import (
+	"app/internal/products/service"
+	"app/internal/products/storage"
+	"app/internal/products/validator"
+	"app/pkg/web/response"
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// columnsProductCSV are the columns of a products csv file, in export order
+var columnsProductCSV = []string{"id", "name", "type", "count", "price"}
+
+// Export writes the products matching the filters as a file, streamed from the storage
+// - format: "csv" (default, the only format supported)
+// - filters: as in GetAll (pagination and sort are ignored, products are ordered by id)
+// - columns: id, name, type, count, price; unknown values are empty cells
+// - name and type starting with =, +, - or @ are prefixed with ', so spreadsheets do not run them as formulas
+// - once the file is being written errors can not change the status, so the response is aborted instead
+func (c *ControllerProduct) Export() http.HandlerFunc {
+	return c.traced("Export", func(w http.ResponseWriter, r *http.Request) {
+		// request
+		if format := r.URL.Query().Get("format"); format != "" && format != "csv" {
+			code := http.StatusBadRequest
+			body := &ResponseBody{Message: "format must be csv", Data: nil, Error: true}
+
+			response.JSON(w, code, body)
+			return
+		}
+		q, err := queryProductGetAll(r)
+		if err != nil {
+			code := http.StatusBadRequest
+			body := &ResponseBody{Message: err.Error(), Data: nil, Error: true}
+
+			response.JSON(w, code, body)
+			return
+		}
+
+		// process and response
+		// -> the header is written with the first product, so a failed query still gets an error response
+		var writer *csv.Writer
+		start := func() error {
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			w.Header().Set("Content-Disposition", `attachment; filename="products.csv"`)
+			w.WriteHeader(http.StatusOK)
+
+			writer = csv.NewWriter(w)
+			return writer.Write(columnsProductCSV)
+		}
+		err = c.service.Each(r.Context(), &q.Filter, func(p *storage.Product) (err error) {
+			if writer == nil {
+				if err = start(); err != nil {
+					return
+				}
+			}
+			return writer.Write(recordProductCSV(p))
+		})
+		switch {
+		case err != nil && writer == nil:
+			c.logError(r, err)
+			code := http.StatusInternalServerError
+			body := &ResponseBody{Message: "internal error", Data: nil, Error: true}
+
+			response.JSON(w, code, body)
+			return
+		case err != nil:
+			// the response started, the client can only notice the truncated body
+			c.logError(r, err)
+			panic(http.ErrAbortHandler)
+		case writer == nil:
+			if err = start(); err != nil {
+				panic(http.ErrAbortHandler)
+			}
+		}
+
+		writer.Flush()
+		if writer.Error() != nil {
+			panic(http.ErrAbortHandler)
+		}
+	})
+}
+
+// recordProductCSV returns the csv record of the product (columns as in columnsProductCSV)
+func recordProductCSV(p *storage.Product) []string {
+	record := []string{strconv.Itoa(p.ID), cellTextCSV(p.Name), "", "", ""}
+	if p.Type != nil {
+		record[2] = cellTextCSV(*p.Type)
+	}
+	if p.Count != nil {
+		record[3] = strconv.Itoa(*p.Count)
+	}
+	if p.Price != nil {
+		record[4] = strconv.FormatFloat(*p.Price, 'f', -1, 64)
+	}
+	return record
+}
+
+// cellTextCSV returns the csv cell of a text value
+// - a value a spreadsheet would run as a formula (=, +, - or @ first) is prefixed with ', so it is shown as text
+func cellTextCSV(v string) string {
+	if v != "" && strings.ContainsRune("=+-@", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
+
+// Import stores the products of a csv file
+// - the body is the file (Content-Type text/csv) or the "file" field of a multipart/form-data form
+//   of at most MaxBytesImport bytes (413 otherwise)
+// - the first line is the header: name is required, type, count and price are optional, in any order
+//   (id is ignored, so an exported file can be imported)
+// - empty cells are stored as unknown
+// - each line is validated and stored on its own like Store, so the valid lines are stored even if others fail;
+//   the response status is 200 if every line was stored, or 207 with the errors of each failed line
+// - an internal error stops the import: the response is 500 with the report of the lines processed until then,
+//   the failed line and the lines not processed (the lines stored before it stay stored)
+const (
+	// MaxLinesImport is the max amount of products of an imported file
+	MaxLinesImport = 100000
+	// MaxMemoryImport is the max amount of bytes of a multipart form kept in memory
+	MaxMemoryImport = 32 << 20
+	// MaxBytesImport is the max amount of bytes of the body of an import request
+	MaxBytesImport = 32 << 20
+)
+type ResponseProductImportLine struct {
+	Line	int						`json:"line"`
+	Message	string					`json:"message"`
+	Errors	[]validator.FieldError	`json:"errors,omitempty"`
+}
+type ResponseProductImport struct {
+	Imported	int							`json:"imported"`
+	Failed		int							`json:"failed"`
+	Lines		[]*ResponseProductImportLine	`json:"lines"`
+}
+type ResponseBodyImport struct {
+	Message string					`json:"message"`
+	Data    *ResponseProductImport	`json:"data"`
+	Error   bool					`json:"error"`
+}
+func (c *ControllerProduct) Import() http.HandlerFunc {
+	return c.traced("Import", func(w http.ResponseWriter, r *http.Request) {
+		// request
+		file, err := fileImport(w, r)
+		if err != nil {
+			var code int; var body *ResponseBody
+			switch {
+			case errors.Is(err, errImportMediaType):
+				code = http.StatusUnsupportedMediaType
+				body = &ResponseBody{Message: "content type must be text/csv or multipart/form-data", Data: nil, Error: true}
+			case errors.Is(err, errImportTooLarge):
+				code = http.StatusRequestEntityTooLarge
+				body = &ResponseBody{Message: fmt.Sprintf("file must have at most %d bytes", MaxBytesImport), Data: nil, Error: true}
+			default:
+				code = http.StatusBadRequest
+				body = &ResponseBody{Message: "invalid file", Data: nil, Error: true}
+			}
+
+			response.JSON(w, code, body)
+			return
+		}
+		defer file.Close()
+
+		// -> read every line before storing any, so a malformed file stores nothing
+		lines, err := readProductsCSV(file)
+		if err != nil {
+			var code int; var body *ResponseBody
+			switch {
+			case errors.Is(err, errImportTooLarge):
+				code = http.StatusRequestEntityTooLarge
+				body = &ResponseBody{Message: fmt.Sprintf("file must have at most %d bytes", MaxBytesImport), Data: nil, Error: true}
+			default:
+				code = http.StatusBadRequest
+				body = &ResponseBody{Message: err.Error(), Data: nil, Error: true}
+			}
+
+			response.JSON(w, code, body)
+			return
+		}
+
+		// process
+		data := &ResponseProductImport{Lines: make([]*ResponseProductImportLine, 0)}
+		var interrupted bool
+		for i, line := range lines {
+			if line.product == nil {
+				data.Lines = append(data.Lines, &ResponseProductImportLine{Line: line.number, Message: line.message})
+				continue
+			}
+
+			// -> validation of the lines with cells that could not be parsed
+			if len(line.errors) > 0 {
+				var fields []validator.FieldError
+				var errValidation *validator.ErrorValidatorProduct
+				if errors.As(c.service.Validate(line.product), &errValidation) {
+					fields = errValidation.Fields
+				}
+				data.Lines = append(data.Lines, &ResponseProductImportLine{Line: line.number, Message: "invalid product", Errors: append(fields, line.errors...)})
+				continue
+			}
+
+			// -> store product
+			err = c.service.Store(r.Context(), line.product)
+			if err != nil {
+				var errValidation *validator.ErrorValidatorProduct
+				switch {
+				case errors.As(err, &errValidation):
+					data.Lines = append(data.Lines, &ResponseProductImportLine{Line: line.number, Message: "invalid product", Errors: errValidation.Fields})
+				case errors.Is(err, service.ErrServiceProductNotUnique):
+					data.Lines = append(data.Lines, &ResponseProductImportLine{Line: line.number, Message: "product not unique"})
+				default:
+					// -> the next lines are not processed, the storage is likely to fail them too
+					c.logError(r, err)
+					data.Lines = append(data.Lines, &ResponseProductImportLine{Line: line.number, Message: "internal error"})
+					for _, next := range lines[i+1:] {
+						data.Lines = append(data.Lines, &ResponseProductImportLine{Line: next.number, Message: "not processed"})
+					}
+					interrupted = true
+				}
+				if interrupted {
+					break
+				}
+				continue
+			}
+			data.Imported++
+		}
+		data.Failed = len(data.Lines)
+
+		// response
+		code := http.StatusOK
+		body := &ResponseBodyImport{Message: "success", Data: data, Error: false}
+		switch {
+		case interrupted:
+			code = http.StatusInternalServerError
+			body.Message = "import interrupted by an internal error"
+			body.Error = true
+		case data.Failed > 0:
+			code = http.StatusMultiStatus
+			body.Message = "some lines failed"
+		}
+
+		response.JSON(w, code, body)
+	})
+}
+
+var (
+	// errImportMediaType is the error of an import request with an unsupported content type
+	errImportMediaType = errors.New("unsupported import media type")
+	// errImportTooLarge is the error of an import request with a body of more than MaxBytesImport bytes
+	errImportTooLarge = errors.New("import file too large")
+)
+
+// errImport returns err as errImportTooLarge if the body of the request exceeded MaxBytesImport
+func errImport(err error) error {
+	var errMaxBytes *http.MaxBytesError
+	if errors.As(err, &errMaxBytes) {
+		return fmt.Errorf("%w. %v", errImportTooLarge, err)
+	}
+	return err
+}
+
+// fileImport returns the csv file of an import request
+// - the body is limited to MaxBytesImport bytes
+func fileImport(w http.ResponseWriter, r *http.Request) (file io.ReadCloser, err error) {
+	r.Body = http.MaxBytesReader(w, r.Body, MaxBytesImport)
+
+	mediaType := "text/csv"
+	if v := r.Header.Get("Content-Type"); v != "" {
+		mediaType, _, err = mime.ParseMediaType(v)
+		if err != nil {
+			err = fmt.Errorf("%w. %v", errImportMediaType, err)
+			return
+		}
+	}
+
+	switch mediaType {
+	case "text/csv":
+		file = r.Body
+	case "multipart/form-data":
+		err = r.ParseMultipartForm(MaxMemoryImport)
+		if err != nil {
+			err = errImport(err)
+			return
+		}
+		file, _, err = r.FormFile("file")
+	default:
+		err = fmt.Errorf("%w. %s", errImportMediaType, mediaType)
+	}
+	return
+}
+
+// lineProductCSV is a product read from a line of a csv file
+type lineProductCSV struct {
+	// number is the line number in the file (the header is line 1)
+	number int
+	// product is the product of the line (unparsable cells are unknown), nil if the line is malformed
+	product *storage.Product
+	// message is why the line is malformed
+	message string
+	// errors are the cells that could not be parsed
+	errors []validator.FieldError
+}
+
+// readProductsCSV reads the products of a csv file
+// - lines with a wrong amount of cells are malformed, other malformed files are an error
+func readProductsCSV(file io.Reader) (lines []*lineProductCSV, err error) {
+	// skip the byte order mark spreadsheets may write
+	br := bufio.NewReader(file)
+	if bom, _ := br.Peek(3); string(bom) == "\xef\xbb\xbf" {
+		br.Discard(3)
+	}
+	reader := csv.NewReader(br)
+
+	// header
+	header, err := reader.Read()
+	if err != nil {
+		if err = errImport(err); !errors.Is(err, errImportTooLarge) {
+			err = errors.New("file must have a header line")
+		}
+		return
+	}
+	columns := make(map[string]int)
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if _, ok := columns[column]; ok || !containsString(columnsProductCSV, column) {
+			err = fmt.Errorf("header columns must be unique and one of %v", columnsProductCSV)
+			return
+		}
+		columns[column] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		err = errors.New("header must have a name column")
+		return
+	}
+
+	// lines
+	for {
+		var record []string
+		record, err = reader.Read()
+		if errors.Is(err, io.EOF) {
+			err = nil
+			break
+		}
+		if err != nil && !errors.Is(err, csv.ErrFieldCount) {
+			if err = errImport(err); !errors.Is(err, errImportTooLarge) {
+				err = fmt.Errorf("invalid csv. %v", err)
+			}
+			return
+		}
+		if len(lines) >= MaxLinesImport {
+			err = fmt.Errorf("file must have at most %d products", MaxLinesImport)
+			return
+		}
+
+		number, _ := reader.FieldPos(0)
+		if err != nil {
+			err = nil
+			lines = append(lines, &lineProductCSV{number: number, message: fmt.Sprintf("line must have %d cells", len(header))})
+			continue
+		}
+		lines = append(lines, parseProductCSV(number, record, columns))
+	}
+
+	return
+}
+
+// parseProductCSV returns the product of a csv record
+func parseProductCSV(number int, record []string, columns map[string]int) (line *lineProductCSV) {
+	line = &lineProductCSV{number: number, product: &storage.Product{}}
+	cell := func(column string) (v string, ok bool) {
+		i, ok := columns[column]
+		if !ok || record[i] == "" {
+			return "", false
+		}
+		return record[i], true
+	}
+
+	if v, ok := cell("name"); ok {
+		line.product.Name = v
+	}
+	if v, ok := cell("type"); ok {
+		line.product.Type = &v
+	}
+	if v, ok := cell("count"); ok {
+		count, err := strconv.Atoi(v)
+		if err != nil {
+			line.errors = append(line.errors, validator.FieldError{Field: "count", Message: "must be an int"})
+		} else {
+			line.product.Count = &count
+		}
+	}
+	if v, ok := cell("price"); ok {
+		price, err := strconv.ParseFloat(v, 64)
+		switch {
+		case err != nil:
+			line.errors = append(line.errors, validator.FieldError{Field: "price", Message: "must be a number"})
+		case math.IsNaN(price) || math.IsInf(price, 0):
+			// ParseFloat accepts NaN, Inf and Infinity
+			line.errors = append(line.errors, validator.FieldError{Field: "price", Message: "must be a finite number"})
+		default:
+			line.product.Price = &price
+		}
+	}
+	return
+}
+
+// containsString reports whether s is in values
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"app/internal/products/service"
+	"app/internal/products/storage"
+	"app/internal/products/validator"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+// Tests for ControllerProduct.Export handler
+func TestControllerProduct_Export(t *testing.T) {
+	type input struct { query string }
+	type output struct { code int; body string }
+	type testCase struct {
+		name string
+		input input
+		output output
+	}
+
+	cases := []testCase{
+		// valid cases
+		{
+			name: "200 - every product, unknown values are empty",
+			input: input{query: "?format=csv"},
+			output: output{code: http.StatusOK, body: "id,name,type,count,price\n1,apple,fruit,3,1.5\n2,\"pear, green\",,0,\n"},
+		},
+		{
+			name: "200 - filtered",
+			input: input{query: "?type=fruit"},
+			output: output{code: http.StatusOK, body: "id,name,type,count,price\n1,apple,fruit,3,1.5\n"},
+		},
+		{
+			name: "200 - no products, only the header",
+			input: input{query: "?name=kiwi"},
+			output: output{code: http.StatusOK, body: "id,name,type,count,price\n"},
+		},
+
+		// invalid cases
+		{
+			name: "400 - unknown format",
+			input: input{query: "?format=xlsx"},
+			output: output{code: http.StatusBadRequest, body: `{"message":"format must be csv","data":null,"error":true}`},
+		},
+	}
+
+	// run tests
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// arrange
+			db := newDbProduct()
+			count := 0
+			db[2] = &storage.Product{ID: 2, Name: "pear, green", Count: &count}
+			r := newRouterProduct(db)
+
+			// act
+			req := httptest.NewRequest(http.MethodGet, "/products/export"+c.input.query, nil)
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			// assert
+			require.Equal(t, c.output.code, rr.Code)
+			if c.output.code != http.StatusOK {
+				require.JSONEq(t, c.output.body, rr.Body.String())
+				return
+			}
+			require.Equal(t, "text/csv; charset=utf-8", rr.Header().Get("Content-Type"))
+			require.Equal(t, c.output.body, rr.Body.String())
+		})
+	}
+
+	t.Run("200 - formulas are exported as text", func(t *testing.T) {
+		// arrange
+		typ := "-1"
+		r := newRouterProduct(map[int]*storage.Product{
+			1: {ID: 1, Name: "=1+2", Type: &typ},
+			2: {ID: 2, Name: "@sum"},
+			3: {ID: 3, Name: "+a", Type: &typ},
+		})
+
+		// act
+		req := httptest.NewRequest(http.MethodGet, "/products/export", nil)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		// assert
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.Equal(t, "id,name,type,count,price\n1,'=1+2,'-1,,\n2,'@sum,,,\n3,'+a,'-1,,\n", rr.Body.String())
+	})
+}
+
+// Tests for ControllerProduct.Import handler
+func TestControllerProduct_Import(t *testing.T) {
+	type input struct { contentType string; body string }
+	type output struct { code int; body string }
+	type testCase struct {
+		name string
+		input input
+		output output
+	}
+
+	cases := []testCase{
+		// valid cases
+		{
+			name: "200 - every line stored",
+			input: input{contentType: "text/csv", body: "\xef\xbb\xbfName,price,count\npear,2.5,\nkiwi,,0\n"},
+			output: output{code: http.StatusOK, body: `{"message":"success","data":{"imported":2,"failed":0,"lines":[]},"error":false}`},
+		},
+		{
+			name: "200 - exported file",
+			input: input{contentType: "text/csv; charset=utf-8", body: "id,name,type,count,price\n7,pear,fruit,3,2.5\n"},
+			output: output{code: http.StatusOK, body: `{"message":"success","data":{"imported":1,"failed":0,"lines":[]},"error":false}`},
+		},
+		{
+			name: "207 - line level errors",
+			input: input{contentType: "text/csv", body: "name,count,price\npear,1,1\n,x,-1\napple,1,1\nkiwi\n"},
+			output: output{code: http.StatusMultiStatus, body: `{"message":"some lines failed","data":{"imported":1,"failed":3,"lines":[
+				{"line":3,"message":"invalid product","errors":[{"field":"name","message":"is required"},{"field":"price","message":"must be non-negative"},{"field":"count","message":"must be an int"}]},
+				{"line":4,"message":"product not unique"},
+				{"line":5,"message":"line must have 3 cells"}
+			]},"error":false}`},
+		},
+		{
+			name: "207 - non finite price",
+			input: input{contentType: "text/csv", body: "name,price\npear,NaN\nkiwi,Inf\nplum,-Infinity\n"},
+			output: output{code: http.StatusMultiStatus, body: `{"message":"some lines failed","data":{"imported":0,"failed":3,"lines":[
+				{"line":2,"message":"invalid product","errors":[{"field":"price","message":"must be a finite number"}]},
+				{"line":3,"message":"invalid product","errors":[{"field":"price","message":"must be a finite number"}]},
+				{"line":4,"message":"invalid product","errors":[{"field":"price","message":"must be a finite number"}]}
+			]},"error":false}`},
+		},
+
+		// invalid cases
+		{
+			name: "400 - header without name",
+			input: input{contentType: "text/csv", body: "type,price\nfruit,1\n"},
+			output: output{code: http.StatusBadRequest, body: `{"message":"header must have a name column","data":null,"error":true}`},
+		},
+		{
+			name: "400 - unknown column",
+			input: input{contentType: "text/csv", body: "name,color\npear,green\n"},
+			output: output{code: http.StatusBadRequest, body: `{"message":"header columns must be unique and one of [id name type count price]","data":null,"error":true}`},
+		},
+		{
+			name: "413 - file too large",
+			input: input{contentType: "text/csv", body: "name\n" + strings.Repeat("a", MaxBytesImport)},
+			output: output{code: http.StatusRequestEntityTooLarge, body: `{"message":"file must have at most 33554432 bytes","data":null,"error":true}`},
+		},
+		{
+			name: "415 - unsupported content type",
+			input: input{contentType: "application/json", body: `{"name":"pear"}`},
+			output: output{code: http.StatusUnsupportedMediaType, body: `{"message":"content type must be text/csv or multipart/form-data","data":null,"error":true}`},
+		},
+	}
+
+	// run tests
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// arrange
+			r := newRouterProduct(newDbProduct())
+
+			// act
+			req := httptest.NewRequest(http.MethodPost, "/products/import", strings.NewReader(c.input.body))
+			req.Header.Set("Content-Type", c.input.contentType)
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			// assert
+			require.Equal(t, c.output.code, rr.Code)
+			require.JSONEq(t, c.output.body, rr.Body.String())
+		})
+	}
+
+	t.Run("200 - multipart form", func(t *testing.T) {
+		// arrange
+		r := newRouterProduct(newDbProduct())
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		fw, err := mw.CreateFormFile("file", "products.csv")
+		require.NoError(t, err)
+		_, err = fw.Write([]byte("name,type\npear,fruit\n"))
+		require.NoError(t, err)
+		require.NoError(t, mw.Close())
+
+		// act
+		req := httptest.NewRequest(http.MethodPost, "/products/import", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		// assert
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.JSONEq(t, `{"message":"success","data":{"imported":1,"failed":0,"lines":[]},"error":false}`, rr.Body.String())
+
+		req = httptest.NewRequest(http.MethodGet, "/products/2", nil)
+		rr = httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		require.JSONEq(t, `{"message":"success","data":{"id":2,"name":"pear","type":"fruit","count":null,"price":null},"error":false}`, rr.Body.String())
+	})
+	t.Run("413 - multipart form too large", func(t *testing.T) {
+		// arrange
+		r := newRouterProduct(newDbProduct())
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		fw, err := mw.CreateFormFile("file", "products.csv")
+		require.NoError(t, err)
+		_, err = fw.Write([]byte("name\n" + strings.Repeat("a", MaxBytesImport)))
+		require.NoError(t, err)
+		require.NoError(t, mw.Close())
+
+		// act
+		req := httptest.NewRequest(http.MethodPost, "/products/import", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		// assert
+		require.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+		require.JSONEq(t, `{"message":"file must have at most 33554432 bytes","data":null,"error":true}`, rr.Body.String())
+	})
+	t.Run("500 - internal error reports the lines processed and not processed", func(t *testing.T) {
+		// arrange
+		st := &storageProductFailing{StorageProduct: storage.NewImplStorageProductMap(newDbProduct()), failOn: 3}
+		sv := service.NewImplServiceProduct(st, validator.NewImplValidatorProduct(nil))
+		ct := NewControllerProduct(sv, slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+		r := chi.NewRouter()
+		r.Post("/products/import", ct.Import())
+
+		// act
+		req := httptest.NewRequest(http.MethodPost, "/products/import", strings.NewReader("name\npear\napple\nkiwi\nplum\n"))
+		req.Header.Set("Content-Type", "text/csv")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		// assert
+		require.Equal(t, http.StatusInternalServerError, rr.Code)
+		require.JSONEq(t, `{"message":"import interrupted by an internal error","data":{"imported":1,"failed":3,"lines":[
+			{"line":3,"message":"product not unique"},
+			{"line":4,"message":"internal error"},
+			{"line":5,"message":"not processed"}
+		]},"error":true}`, rr.Body.String())
+	})
+}
+
+// storageProductFailing is a StorageProduct whose Store fails with an internal error from its call number failOn
+type storageProductFailing struct {
+	storage.StorageProduct
+	// failOn is the first call of Store that fails
+	failOn int
+	// calls is the amount of calls of Store
+	calls int
+}
+
+func (st *storageProductFailing) Store(ctx context.Context, p *storage.Product) (err error) {
+	st.calls++
+	if st.calls >= st.failOn {
+		return fmt.Errorf("%w. %v", storage.ErrStorageProductInternal, errors.New("connection lost"))
+	}
+	return st.StorageProduct.Store(ctx, p)
+}
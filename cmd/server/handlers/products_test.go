@@ -0,0 +1,575 @@
+package handlers
+
+import (
+	"app/internal/products/service"
+	"app/internal/products/storage"
+	"app/internal/products/validator"
+	"app/pkg/trace"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+// newRouterProduct returns a router with the product routes backed by a service on an in-memory storage
+func newRouterProduct(db map[int]*storage.Product) http.Handler {
+	st := storage.NewImplStorageProductMap(db)
+	vl := validator.NewImplValidatorProduct(nil)
+	sv := service.NewImplServiceProduct(st, vl)
+	ct := NewControllerProduct(sv, slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+
+	r := chi.NewRouter()
+	r.Get("/products", ct.GetAll())
+	r.Get("/products/export", ct.Export())
+	r.Get("/products/{id}", ct.GetOne())
+	r.Post("/products", ct.Store())
+	r.Post("/products/batch", ct.Batch())
+	r.Post("/products/import", ct.Import())
+	r.Put("/products/{id}", ct.Update())
+	r.Patch("/products/{id}", ct.Patch())
+	r.Delete("/products/{id}", ct.Delete())
+	return r
+}
+
+// newDbProduct returns a storage map with one product (id 1)
+func newDbProduct() map[int]*storage.Product {
+	typ := "fruit"
+	count := 3
+	price := 1.5
+	updatedAt := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	return map[int]*storage.Product{
+		1: {ID: 1, Name: "apple", Type: &typ, Count: &count, Price: &price, Version: 1, UpdatedAt: updatedAt},
+	}
+}
+
+// Tests for ControllerProduct.GetOne handler
+func TestControllerProduct_GetOne(t *testing.T) {
+	type input struct { header http.Header }
+	type output struct { code int; body string }
+	type testCase struct {
+		name string
+		input input
+		output output
+	}
+
+	cases := []testCase{
+		{
+			name: "200 - without conditions",
+			input: input{header: http.Header{}},
+			output: output{code: http.StatusOK, body: `{"message":"success","data":{"id":1,"name":"apple","type":"fruit","count":3,"price":1.5},"error":false}`},
+		},
+		{
+			name: "200 - If-None-Match with another etag",
+			input: input{header: http.Header{"If-None-Match": {`"0"`}, "If-Modified-Since": {"Sun, 01 Oct 2023 12:00:00 GMT"}}},
+			output: output{code: http.StatusOK, body: `{"message":"success","data":{"id":1,"name":"apple","type":"fruit","count":3,"price":1.5},"error":false}`},
+		},
+		{
+			name: "200 - modified since",
+			input: input{header: http.Header{"If-Modified-Since": {"Sun, 01 Oct 2023 11:00:00 GMT"}}},
+			output: output{code: http.StatusOK, body: `{"message":"success","data":{"id":1,"name":"apple","type":"fruit","count":3,"price":1.5},"error":false}`},
+		},
+		{
+			name: "304 - If-None-Match with the current etag",
+			input: input{header: http.Header{"If-None-Match": {`"1"`}}},
+			output: output{code: http.StatusNotModified, body: ``},
+		},
+		{
+			name: "304 - not modified since",
+			input: input{header: http.Header{"If-Modified-Since": {"Sun, 01 Oct 2023 12:00:00 GMT"}}},
+			output: output{code: http.StatusNotModified, body: ``},
+		},
+	}
+
+	// run tests
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// arrange
+			r := newRouterProduct(newDbProduct())
+
+			// act
+			req := httptest.NewRequest(http.MethodGet, "/products/1", nil)
+			req.Header = c.input.header
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			// assert
+			require.Equal(t, c.output.code, rr.Code)
+			require.Equal(t, `"1"`, rr.Header().Get("ETag"))
+			require.Equal(t, "Sun, 01 Oct 2023 12:00:00 GMT", rr.Header().Get("Last-Modified"))
+			if c.output.body == "" {
+				require.Empty(t, rr.Body.String())
+				return
+			}
+			require.JSONEq(t, c.output.body, rr.Body.String())
+		})
+	}
+}
+
+// Tests for ControllerProduct.GetAll handler
+func TestControllerProduct_GetAll(t *testing.T) {
+	type input struct { query string }
+	type output struct { code int; body string }
+	type testCase struct {
+		name string
+		input input
+		output output
+	}
+
+	apple := `{"id":1,"name":"apple","type":"fruit","count":3,"price":1.5}`
+	carrot := `{"id":2,"name":"carrot","type":"vegetable","count":10,"price":0.5}`
+	pear := `{"id":3,"name":"pear","type":"fruit","count":0,"price":2}`
+	cases := []testCase{
+		// valid cases
+		{
+			name: "200 - default limit and offset",
+			input: input{query: ""},
+			output: output{code: http.StatusOK, body: `{"message":"success","data":{"items":[` + apple + `,` + carrot + `,` + pear + `],"total":3,"limit":10,"offset":0},"error":false}`},
+		},
+		{
+			name: "200 - limit and offset",
+			input: input{query: "?limit=1&offset=1"},
+			output: output{code: http.StatusOK, body: `{"message":"success","data":{"items":[` + carrot + `],"total":3,"limit":1,"offset":1},"error":false}`},
+		},
+		{
+			name: "200 - max limit",
+			input: input{query: "?limit=100"},
+			output: output{code: http.StatusOK, body: `{"message":"success","data":{"items":[` + apple + `,` + carrot + `,` + pear + `],"total":3,"limit":100,"offset":0},"error":false}`},
+		},
+		{
+			name: "200 - offset after the last product",
+			input: input{query: "?offset=5"},
+			output: output{code: http.StatusOK, body: `{"message":"success","data":{"items":[],"total":3,"limit":10,"offset":5},"error":false}`},
+		},
+		{
+			name: "200 - filter and sort",
+			input: input{query: "?type=fruit&sort=-price"},
+			output: output{code: http.StatusOK, body: `{"message":"success","data":{"items":[` + pear + `,` + apple + `],"total":2,"limit":10,"offset":0},"error":false}`},
+		},
+
+		// invalid cases
+		{
+			name: "400 - limit 0",
+			input: input{query: "?limit=0"},
+			output: output{code: http.StatusBadRequest, body: `{"message":"limit must be an int between 1 and 100","data":null,"error":true}`},
+		},
+		{
+			name: "400 - limit over the max",
+			input: input{query: "?limit=101"},
+			output: output{code: http.StatusBadRequest, body: `{"message":"limit must be an int between 1 and 100","data":null,"error":true}`},
+		},
+		{
+			name: "400 - limit not an int",
+			input: input{query: "?limit=ten"},
+			output: output{code: http.StatusBadRequest, body: `{"message":"limit must be an int between 1 and 100","data":null,"error":true}`},
+		},
+		{
+			name: "400 - negative offset",
+			input: input{query: "?offset=-1"},
+			output: output{code: http.StatusBadRequest, body: `{"message":"offset must be a non-negative int","data":null,"error":true}`},
+		},
+		{
+			name: "400 - filter not a number",
+			input: input{query: "?min_price=cheap"},
+			output: output{code: http.StatusBadRequest, body: `{"message":"min_price must be a number","data":null,"error":true}`},
+		},
+		{
+			name: "400 - unknown sort field",
+			input: input{query: "?sort=color"},
+			output: output{code: http.StatusBadRequest, body: `{"message":"sort fields must be one of [id name type count price]","data":null,"error":true}`},
+		},
+	}
+
+	// run tests
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// arrange
+			db := newDbProduct()
+			vegetable, fruit := "vegetable", "fruit"
+			count10, count0 := 10, 0
+			price05, price2 := 0.5, 2.0
+			db[2] = &storage.Product{ID: 2, Name: "carrot", Type: &vegetable, Count: &count10, Price: &price05, Version: 1}
+			db[3] = &storage.Product{ID: 3, Name: "pear", Type: &fruit, Count: &count0, Price: &price2, Version: 1}
+			r := newRouterProduct(db)
+
+			// act
+			req := httptest.NewRequest(http.MethodGet, "/products"+c.input.query, nil)
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			// assert
+			require.Equal(t, c.output.code, rr.Code)
+			require.JSONEq(t, c.output.body, rr.Body.String())
+		})
+	}
+}
+
+// Tests for ControllerProduct.Store handler
+func TestControllerProduct_Store(t *testing.T) {
+	t.Run("201 - created with id and location", func(t *testing.T) {
+		// arrange
+		r := newRouterProduct(newDbProduct())
+
+		// act
+		req := httptest.NewRequest(http.MethodPost, "/products", strings.NewReader(`{"name":"pear","type":"fruit","count":0,"price":null}`))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		// assert
+		require.Equal(t, http.StatusCreated, rr.Code)
+		require.Equal(t, "/products/2", rr.Header().Get("Location"))
+		require.Equal(t, `"1"`, rr.Header().Get("ETag"))
+		require.JSONEq(t, `{"message":"success","data":{"id":2,"name":"pear","type":"fruit","count":0,"price":null},"error":false}`, rr.Body.String())
+	})
+
+	t.Run("400 - product not unique", func(t *testing.T) {
+		// arrange
+		r := newRouterProduct(newDbProduct())
+
+		// act
+		req := httptest.NewRequest(http.MethodPost, "/products", strings.NewReader(`{"name":"apple"}`))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		// assert
+		require.Equal(t, http.StatusBadRequest, rr.Code)
+		require.JSONEq(t, `{"message":"product not unique","data":null,"error":true}`, rr.Body.String())
+	})
+}
+
+// Tests for ControllerProduct.Update handler
+func TestControllerProduct_Update(t *testing.T) {
+	t.Run("200 - full replacement", func(t *testing.T) {
+		// arrange
+		r := newRouterProduct(newDbProduct())
+
+		// act
+		req := httptest.NewRequest(http.MethodPut, "/products/1", strings.NewReader(`{"name":"pear","type":null,"count":0,"price":2}`))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		// assert
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.JSONEq(t, `{"message":"success","data":{"id":1,"name":"pear","type":null,"count":0,"price":2},"error":false}`, rr.Body.String())
+	})
+
+	t.Run("200 - If-Match with the current etag", func(t *testing.T) {
+		// arrange
+		r := newRouterProduct(newDbProduct())
+
+		// act
+		req := httptest.NewRequest(http.MethodPut, "/products/1", strings.NewReader(`{"name":"pear","type":null,"count":0,"price":2}`))
+		req.Header.Set("If-Match", `"1"`)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		// assert
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.Equal(t, `"2"`, rr.Header().Get("ETag"))
+	})
+
+	t.Run("412 - If-Match with a stale etag", func(t *testing.T) {
+		// arrange
+		r := newRouterProduct(newDbProduct())
+
+		// act
+		req := httptest.NewRequest(http.MethodPut, "/products/1", strings.NewReader(`{"name":"pear","type":null,"count":0,"price":2}`))
+		req.Header.Set("If-Match", `"0"`)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		// assert
+		require.Equal(t, http.StatusPreconditionFailed, rr.Code)
+		require.JSONEq(t, `{"message":"precondition failed","data":null,"error":true}`, rr.Body.String())
+	})
+
+	t.Run("422 - missing fields", func(t *testing.T) {
+		// arrange
+		r := newRouterProduct(newDbProduct())
+
+		// act
+		req := httptest.NewRequest(http.MethodPut, "/products/1", strings.NewReader(`{"name":"pear"}`))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		// assert
+		require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+		require.JSONEq(t, `{"message":"invalid product","data":[{"field":"type","message":"is required"},{"field":"count","message":"is required"},{"field":"price","message":"is required"}],"error":true}`, rr.Body.String())
+	})
+
+	t.Run("404 - product not found", func(t *testing.T) {
+		// arrange
+		r := newRouterProduct(nil)
+
+		// act
+		req := httptest.NewRequest(http.MethodPut, "/products/1", strings.NewReader(`{"name":"pear","type":null,"count":null,"price":null}`))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		// assert
+		require.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
+
+// Tests for ControllerProduct.Patch handler
+func TestControllerProduct_Patch(t *testing.T) {
+	type input struct { contentType string; body string }
+	type output struct { code int; body string }
+	type testCase struct {
+		name string
+		input input
+		output output
+	}
+
+	cases := []testCase{
+		// valid cases
+		{
+			name: "200 - merge patch clears and sets fields",
+			input: input{contentType: "application/merge-patch+json", body: `{"type":null,"count":0}`},
+			output: output{code: http.StatusOK, body: `{"message":"success","data":{"id":1,"name":"apple","type":null,"count":0,"price":1.5},"error":false}`},
+		},
+		{
+			name: "200 - json patch",
+			input: input{contentType: "application/json-patch+json", body: `[{"op":"test","path":"/name","value":"apple"},{"op":"replace","path":"/price","value":2.5},{"op":"remove","path":"/count"}]`},
+			output: output{code: http.StatusOK, body: `{"message":"success","data":{"id":1,"name":"apple","type":"fruit","count":null,"price":2.5},"error":false}`},
+		},
+
+		// invalid cases
+		{
+			name: "415 - unsupported content type",
+			input: input{contentType: "application/json", body: `{"count":0}`},
+			output: output{code: http.StatusUnsupportedMediaType, body: `{"message":"content type must be application/merge-patch+json or application/json-patch+json","data":null,"error":true}`},
+		},
+		{
+			name: "409 - json patch test failed",
+			input: input{contentType: "application/json-patch+json", body: `[{"op":"test","path":"/name","value":"pear"}]`},
+			output: output{code: http.StatusConflict, body: `{"message":"patch can not be applied","data":null,"error":true}`},
+		},
+		{
+			name: "400 - unknown field",
+			input: input{contentType: "application/merge-patch+json", body: `{"color":"red"}`},
+			output: output{code: http.StatusBadRequest, body: `{"message":"invalid patch","data":null,"error":true}`},
+		},
+		{
+			name: "422 - patched product is invalid",
+			input: input{contentType: "application/merge-patch+json", body: `{"name":null,"price":-1}`},
+			output: output{code: http.StatusUnprocessableEntity, body: `{"message":"invalid product","data":[{"field":"name","message":"is required"},{"field":"price","message":"must be non-negative"}],"error":true}`},
+		},
+	}
+
+	// run tests
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// arrange
+			r := newRouterProduct(newDbProduct())
+
+			// act
+			req := httptest.NewRequest(http.MethodPatch, "/products/1", strings.NewReader(c.input.body))
+			req.Header.Set("Content-Type", c.input.contentType)
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			// assert
+			require.Equal(t, c.output.code, rr.Code)
+			require.JSONEq(t, c.output.body, rr.Body.String())
+		})
+	}
+}
+
+// Tests for ControllerProduct.Delete handler
+func TestControllerProduct_Delete(t *testing.T) {
+	type input struct { ifMatch string }
+	type output struct { code int }
+	type testCase struct {
+		name string
+		input input
+		output output
+	}
+
+	cases := []testCase{
+		{name: "200 - without precondition", input: input{ifMatch: ""}, output: output{code: http.StatusOK}},
+		{name: "200 - If-Match with the current etag", input: input{ifMatch: `"1"`}, output: output{code: http.StatusOK}},
+		{name: "200 - If-Match wildcard", input: input{ifMatch: `*`}, output: output{code: http.StatusOK}},
+		{name: "412 - If-Match with a stale etag", input: input{ifMatch: `"2"`}, output: output{code: http.StatusPreconditionFailed}},
+	}
+
+	// run tests
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// arrange
+			r := newRouterProduct(newDbProduct())
+
+			// act
+			req := httptest.NewRequest(http.MethodDelete, "/products/1", nil)
+			if c.input.ifMatch != "" {
+				req.Header.Set("If-Match", c.input.ifMatch)
+			}
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			// assert
+			require.Equal(t, c.output.code, rr.Code)
+		})
+	}
+}
+
+// Tests for ControllerProduct.Batch handler
+func TestControllerProduct_Batch(t *testing.T) {
+	type input struct { body string }
+	type output struct { code int; body string }
+	type testCase struct {
+		name string
+		input input
+		output output
+	}
+
+	cases := []testCase{
+		// valid cases
+		{
+			name: "200 - atomic create",
+			input: input{body: `{"operation":"create","items":[{"name":"pear"},{"name":"kiwi","count":0}]}`},
+			output: output{code: http.StatusOK, body: `{"message":"success","data":{"operation":"create","mode":"atomic","items":[
+				{"index":0,"status":201,"message":"created","data":{"id":2,"name":"pear","type":null,"count":null,"price":null}},
+				{"index":1,"status":201,"message":"created","data":{"id":3,"name":"kiwi","type":null,"count":0,"price":null}}
+			]},"error":false}`},
+		},
+		{
+			name: "207 - best effort delete",
+			input: input{body: `{"operation":"delete","mode":"best_effort","items":[{"id":1},{"id":2}]}`},
+			output: output{code: http.StatusMultiStatus, body: `{"message":"some items failed","data":{"operation":"delete","mode":"best_effort","items":[
+				{"index":0,"status":200,"message":"deleted","data":null},
+				{"index":1,"status":404,"message":"product not found","data":null}
+			]},"error":false}`},
+		},
+		{
+			name: "200 - atomic update",
+			input: input{body: `{"operation":"update","items":[{"id":1,"name":"pear"}]}`},
+			output: output{code: http.StatusOK, body: `{"message":"success","data":{"operation":"update","mode":"atomic","items":[
+				{"index":0,"status":200,"message":"updated","data":{"id":1,"name":"pear","type":null,"count":null,"price":null}}
+			]},"error":false}`},
+		},
+
+		// invalid cases
+		{
+			name: "422 - atomic create with an invalid item",
+			input: input{body: `{"operation":"create","mode":"atomic","items":[{"name":"pear"},{"name":"kiwi","price":-1}]}`},
+			output: output{code: http.StatusUnprocessableEntity, body: `{"message":"batch not applied","data":{"operation":"create","mode":"atomic","items":[
+				{"index":0,"status":424,"message":"not applied, another item failed","data":null},
+				{"index":1,"status":422,"message":"invalid product","data":null,"errors":[{"field":"price","message":"must be non-negative"}]}
+			]},"error":true}`},
+		},
+		{
+			name: "400 - atomic create not unique",
+			input: input{body: `{"operation":"create","items":[{"name":"pear"},{"name":"apple"}]}`},
+			output: output{code: http.StatusBadRequest, body: `{"message":"batch not applied","data":{"operation":"create","mode":"atomic","items":[
+				{"index":0,"status":424,"message":"not applied, another item failed","data":null},
+				{"index":1,"status":400,"message":"product not unique","data":null}
+			]},"error":true}`},
+		},
+		{
+			name: "404 - best effort delete with every item failed",
+			input: input{body: `{"operation":"delete","mode":"best_effort","items":[{"id":2},{"id":3}]}`},
+			output: output{code: http.StatusNotFound, body: `{"message":"batch not applied","data":{"operation":"delete","mode":"best_effort","items":[
+				{"index":0,"status":404,"message":"product not found","data":null},
+				{"index":1,"status":404,"message":"product not found","data":null}
+			]},"error":true}`},
+		},
+		{
+			name: "400 - unknown operation",
+			input: input{body: `{"operation":"upsert","items":[{"name":"pear"}]}`},
+			output: output{code: http.StatusBadRequest, body: `{"message":"operation must be create, update or delete","data":null,"error":true}`},
+		},
+	}
+
+	// run tests
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// arrange
+			r := newRouterProduct(newDbProduct())
+
+			// act
+			req := httptest.NewRequest(http.MethodPost, "/products/batch", strings.NewReader(c.input.body))
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			// assert
+			require.Equal(t, c.output.code, rr.Code)
+			require.JSONEq(t, c.output.body, rr.Body.String())
+		})
+	}
+	t.Run("500 - atomic batch failed as a whole", func(t *testing.T) {
+		// arrange
+		sv := &serviceProductAborted{ServiceProduct: service.NewImplServiceProduct(storage.NewImplStorageProductMap(newDbProduct()), validator.NewImplValidatorProduct(nil))}
+		ct := NewControllerProduct(sv, slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+		r := chi.NewRouter()
+		r.Post("/products/batch", ct.Batch())
+
+		// act
+		req := httptest.NewRequest(http.MethodPost, "/products/batch", strings.NewReader(`{"operation":"create","items":[{"name":"pear"},{"name":"kiwi"}]}`))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		// assert
+		require.Equal(t, http.StatusInternalServerError, rr.Code)
+		require.JSONEq(t, `{"message":"batch not applied","data":{"operation":"create","mode":"atomic","items":[
+			{"index":0,"status":424,"message":"not applied, another item failed","data":null},
+			{"index":1,"status":424,"message":"not applied, another item failed","data":null}
+		]},"error":true}`, rr.Body.String())
+	})
+}
+
+// serviceProductAborted is a ServiceProduct whose StoreMany fails as a whole, as a commit that fails
+type serviceProductAborted struct {
+	service.ServiceProduct
+}
+
+func (sv *serviceProductAborted) StoreMany(ctx context.Context, ps []*storage.Product, mode storage.BatchMode) (errs []error, err error) {
+	errs = make([]error, len(ps))
+	for i := range errs {
+		errs[i] = service.ErrServiceProductRolledBack
+	}
+	err = fmt.Errorf("%w. %v", service.ErrServiceProductInternal, errors.New("commit failed"))
+	return
+}
+
+// exporterRecorder is a trace.Exporter that keeps the exported spans
+type exporterRecorder struct {
+	spans []*trace.SpanData
+}
+
+func (e *exporterRecorder) Export(span *trace.SpanData) (err error) {
+	e.spans = append(e.spans, span)
+	return
+}
+
+// Tests for ControllerProduct.traced method
+func TestControllerProduct_traced(t *testing.T) {
+	// arrange
+	exporter := &exporterRecorder{}
+	tracer := trace.NewTracer(exporter, nil)
+	sv := service.NewImplServiceProduct(storage.NewImplStorageProductMap(newDbProduct()), validator.NewImplValidatorProduct(nil))
+	ct := NewControllerProduct(sv, slog.New(slog.NewTextHandler(io.Discard, nil)), tracer)
+	r := chi.NewRouter()
+	r.Get("/products/{id}", ct.GetOne())
+	ctx, parent := tracer.Start(context.Background(), "request")
+	req := httptest.NewRequest(http.MethodGet, "/products/1", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+
+	// act
+	r.ServeHTTP(w, req)
+
+	// assert
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, exporter.spans, 1)
+	require.Equal(t, "ControllerProduct.GetOne", exporter.spans[0].Name)
+	require.Equal(t, parent.Context().TraceID.String(), exporter.spans[0].TraceID)
+	require.Equal(t, parent.Context().SpanID.String(), exporter.spans[0].ParentSpanID)
+}
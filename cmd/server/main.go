@@ -2,33 +2,33 @@ package main
 
 import (
 	"app/cmd/server/dependencies"
+	"app/pkg/config"
+	"errors"
+	"flag"
+	"fmt"
 	"os"
-
-	"github.com/go-sql-driver/mysql"
 )
 
 func main() {
-	// env
-	// ...
-
-
 	// app
-	// -> cfg
-	cfg := &dependencies.Config{
-		// database
-		DbMySQL: &mysql.Config{
-			User: os.Getenv("DB_MYSQL_USER"),
-			Passwd: os.Getenv("DB_MYSQL_PASSWORD"),
-			Net: "tcp",
-			Addr: os.Getenv("DB_MYSQL_ADDR"),
-			DBName: os.Getenv("DB_MYSQL_DATABASE"),
-			ParseTime: true,
-		},
-		// server
-		Server: &dependencies.ConfigServer{
-			Host: os.Getenv("SERVER_HOST"),
-			Port: 8080,
-		},
+	// -> cfg (defaults < config file < env < flags)
+	cfg := dependencies.DefaultConfig()
+	args, err := config.Load(cfg, os.Args[1:], os.LookupEnv)
+	if err == nil && len(args) > 0 {
+		err = fmt.Errorf("unexpected arguments %q", args)
+	}
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	// -> the effective config goes to stderr, stdout only carries the JSON logs
+	fmt.Fprintln(os.Stderr, "config:")
+	if err := config.Print(os.Stderr, cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 
 	app := dependencies.NewApplication(cfg)
@@ -37,4 +37,4 @@ func main() {
 	if err := app.Run(); err != nil {
 		panic(err)
 	}
-}
\ No newline at end of file
+}
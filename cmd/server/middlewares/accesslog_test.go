@@ -0,0 +1,45 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+// Tests for AccessLog middleware
+func TestAccessLog(t *testing.T) {
+	// arrange
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	r := chi.NewRouter()
+	r.Use(RequestID)
+	r.Use(AccessLog(logger))
+	r.Get("/products/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("not found"))
+	})
+	req := httptest.NewRequest(http.MethodGet, "/products/7", nil)
+	req.Header.Set(HeaderRequestID, "abc")
+	res := httptest.NewRecorder()
+
+	// act
+	r.ServeHTTP(res, req)
+
+	// assert
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	require.Equal(t, "request", entry["msg"])
+	require.Equal(t, "abc", entry["request_id"])
+	require.Equal(t, "GET", entry["method"])
+	require.Equal(t, "/products/{id}", entry["route"])
+	require.Equal(t, "/products/7", entry["path"])
+	require.Equal(t, float64(http.StatusNotFound), entry["status"])
+	require.Equal(t, float64(len("not found")), entry["bytes"])
+	require.Contains(t, entry, "latency")
+}
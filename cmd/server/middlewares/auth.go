@@ -0,0 +1,170 @@
+package middlewares
+
+import (
+	"app/pkg/jwt"
+	"app/pkg/web/response"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// HeaderAPIKey is the header with the API key of a request
+const HeaderAPIKey = "X-API-Key"
+
+// authentication methods
+const (
+	// AuthMethodAPIKey is the authentication with an API key
+	AuthMethodAPIKey = "api_key"
+	// AuthMethodJWT is the authentication with a bearer JWT
+	AuthMethodJWT = "jwt"
+)
+
+var (
+	// errAuthMissing is the failure of a request without credentials
+	errAuthMissing = errors.New("missing credentials")
+	// errAuthAPIKey is the failure of a request with an unknown API key
+	errAuthAPIKey = errors.New("unknown api key")
+)
+
+// Principal is the authenticated client of a request
+type Principal struct {
+	// Subject identifies the client: the name of its API key, or the sub claim of its token
+	Subject	string
+	// Method is how the client authenticated: AuthMethodAPIKey or AuthMethodJWT
+	Method	string
+	// Claims are the claims of the token (nil with an API key)
+	Claims	*jwt.Claims
+}
+
+// keyPrincipal is the context key of the principal
+type keyPrincipal struct{}
+
+// PrincipalFrom returns the principal of the request of ctx (nil if the request is not authenticated)
+func PrincipalFrom(ctx context.Context) *Principal {
+	p, _ := ctx.Value(keyPrincipal{}).(*Principal)
+	return p
+}
+
+// ConfigAuth is the configuration of Auth
+type ConfigAuth struct {
+	// APIKeys are the accepted API keys, by key the name of their client
+	APIKeys map[string]string
+	// Verifier verifies the bearer JWTs (nil rejects them)
+	Verifier *jwt.Verifier
+	// Logger logs why the credentials were rejected (slog.Default() by default)
+	Logger *slog.Logger
+}
+
+// apiKey is an accepted API key
+type apiKey struct {
+	// hash is the SHA-256 of the key, so keys are compared in constant time whatever their length
+	hash	[32]byte
+	// name is the name of the client of the key
+	name	string
+}
+
+// Auth authenticates the requests and sets their principal in their context
+// - credentials: an API key in the X-API-Key header, or in the Authorization header as a bearer token,
+//   or a JWT as a bearer token (a bearer token with three dot separated parts is a JWT)
+// - requests without valid credentials get 401 Unauthorized, with a WWW-Authenticate challenge
+func Auth(cfg *ConfigAuth) func(next http.Handler) http.Handler {
+	// default config
+	defaultCfg := ConfigAuth{
+		Logger: slog.Default(),
+	}
+	if cfg != nil {
+		defaultCfg.APIKeys = cfg.APIKeys
+		defaultCfg.Verifier = cfg.Verifier
+		if cfg.Logger != nil {
+			defaultCfg.Logger = cfg.Logger
+		}
+	}
+	keys := make([]apiKey, 0, len(defaultCfg.APIKeys))
+	for key, name := range defaultCfg.APIKeys {
+		keys = append(keys, apiKey{hash: sha256.Sum256([]byte(key)), name: name})
+	}
+
+	// authenticate returns the principal of the credentials of r
+	authenticate := func(r *http.Request) (p *Principal, err error) {
+		key := r.Header.Get(HeaderAPIKey)
+		if key == "" {
+			scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+			if !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+				err = errAuthMissing
+				return
+			}
+			token = strings.TrimSpace(token)
+
+			// -> jwt
+			if strings.Count(token, ".") == 2 {
+				if defaultCfg.Verifier == nil {
+					err = jwt.ErrTokenAlgorithm
+					return
+				}
+				var claims *jwt.Claims
+				claims, err = defaultCfg.Verifier.Verify(token)
+				if err != nil {
+					return
+				}
+				if claims.Subject == "" {
+					err = fmt.Errorf("%w. sub is required", jwt.ErrTokenClaims)
+					return
+				}
+				p = &Principal{Subject: claims.Subject, Method: AuthMethodJWT, Claims: claims}
+				return
+			}
+			key = token
+		}
+
+		// -> api key (every key is compared, so the time does not tell which one was close)
+		hash := sha256.Sum256([]byte(key))
+		var name string
+		for _, k := range keys {
+			if subtle.ConstantTimeCompare(hash[:], k.hash[:]) == 1 {
+				name = k.name
+			}
+		}
+		if name == "" {
+			err = errAuthAPIKey
+			return
+		}
+		p = &Principal{Subject: name, Method: AuthMethodAPIKey}
+		return
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, err := authenticate(r)
+			if err != nil {
+				defaultCfg.Logger.LogAttrs(r.Context(), slog.LevelInfo, "authentication failed",
+					slog.String("method", r.Method),
+					slog.String("error", err.Error()),
+				)
+
+				// response
+				var code int
+				var body *responseBodyError
+				switch {
+				case errors.Is(err, errAuthMissing):
+					code = http.StatusUnauthorized
+					body = &responseBodyError{Message: "missing credentials", Data: nil, Error: true}
+					w.Header().Set("WWW-Authenticate", `Bearer`)
+				default:
+					code = http.StatusUnauthorized
+					body = &responseBodyError{Message: "invalid credentials", Data: nil, Error: true}
+					w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				}
+
+				response.JSON(w, code, body)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), keyPrincipal{}, p)))
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package middlewares
+
+import (
+	"app/pkg/jwt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Tests for Auth middleware
+func TestAuth(t *testing.T) {
+	// tokens
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	exp := time.Now().Add(time.Hour).Unix()
+	token := func(c *jwt.Claims, secret []byte) string {
+		token, err := jwt.SignHS256(c, secret)
+		require.NoError(t, err)
+		return token
+	}
+
+	type input struct { header http.Header }
+	type output struct { code int; body string; principal *Principal; challenge string }
+	type testCase struct {
+		name string
+		input input
+		output output
+	}
+
+	claims := &jwt.Claims{Subject: "alice", ExpiresAt: exp}
+	cases := []testCase{
+		// valid cases
+		{
+			name: "api key header",
+			input: input{header: http.Header{http.CanonicalHeaderKey(HeaderAPIKey): {"key-ci-0123456789"}}},
+			output: output{code: http.StatusOK, principal: &Principal{Subject: "ci", Method: AuthMethodAPIKey}},
+		},
+		{
+			name: "api key bearer",
+			input: input{header: http.Header{"Authorization": {"bearer key-ops-0123456789"}}},
+			output: output{code: http.StatusOK, principal: &Principal{Subject: "ops", Method: AuthMethodAPIKey}},
+		},
+		{
+			name: "jwt bearer",
+			input: input{header: http.Header{"Authorization": {"Bearer " + token(claims, secret)}}},
+			output: output{code: http.StatusOK, principal: &Principal{Subject: "alice", Method: AuthMethodJWT, Claims: claims}},
+		},
+
+		// invalid cases
+		{
+			name: "missing credentials",
+			input: input{header: http.Header{}},
+			output: output{code: http.StatusUnauthorized, body: `{"message":"missing credentials","data":null,"error":true}`, challenge: `Bearer`},
+		},
+		{
+			name: "basic scheme",
+			input: input{header: http.Header{"Authorization": {"Basic dXNlcjpwYXNz"}}},
+			output: output{code: http.StatusUnauthorized, body: `{"message":"missing credentials","data":null,"error":true}`, challenge: `Bearer`},
+		},
+		{
+			name: "unknown api key",
+			input: input{header: http.Header{http.CanonicalHeaderKey(HeaderAPIKey): {"key-ci"}}},
+			output: output{code: http.StatusUnauthorized, body: `{"message":"invalid credentials","data":null,"error":true}`, challenge: `Bearer error="invalid_token"`},
+		},
+		{
+			name: "jwt wrong secret",
+			input: input{header: http.Header{"Authorization": {"Bearer " + token(claims, []byte("other"))}}},
+			output: output{code: http.StatusUnauthorized, body: `{"message":"invalid credentials","data":null,"error":true}`, challenge: `Bearer error="invalid_token"`},
+		},
+		{
+			name: "jwt expired",
+			input: input{header: http.Header{"Authorization": {"Bearer " + token(&jwt.Claims{Subject: "alice", ExpiresAt: 1}, secret)}}},
+			output: output{code: http.StatusUnauthorized, body: `{"message":"invalid credentials","data":null,"error":true}`, challenge: `Bearer error="invalid_token"`},
+		},
+		{
+			name: "jwt without subject",
+			input: input{header: http.Header{"Authorization": {"Bearer " + token(&jwt.Claims{ExpiresAt: exp}, secret)}}},
+			output: output{code: http.StatusUnauthorized, body: `{"message":"invalid credentials","data":null,"error":true}`, challenge: `Bearer error="invalid_token"`},
+		},
+	}
+
+	// run tests
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// arrange
+			var principal *Principal
+			hd := Auth(&ConfigAuth{
+				APIKeys: map[string]string{"key-ci-0123456789": "ci", "key-ops-0123456789": "ops"},
+				Verifier: jwt.NewVerifier(&jwt.ConfigVerifier{SecretHS256: secret}),
+				Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+			})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				principal = PrincipalFrom(r.Context())
+			}))
+			req := httptest.NewRequest(http.MethodDelete, "/products/1", nil)
+			req.Header = c.input.header
+			w := httptest.NewRecorder()
+
+			// act
+			hd.ServeHTTP(w, req)
+
+			// assert
+			require.Equal(t, c.output.code, w.Code)
+			require.Equal(t, c.output.principal, principal)
+			if c.output.body != "" {
+				require.JSONEq(t, c.output.body, w.Body.String())
+				require.Equal(t, c.output.challenge, w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+
+	t.Run("jwt without verifier", func(t *testing.T) {
+		// arrange
+		hd := Auth(&ConfigAuth{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		req := httptest.NewRequest(http.MethodGet, "/products", nil)
+		req.Header.Set("Authorization", "Bearer "+token(claims, secret))
+		w := httptest.NewRecorder()
+
+		// act
+		hd.ServeHTTP(w, req)
+
+		// assert
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
@@ -0,0 +1,53 @@
+package middlewares
+
+import (
+	"app/pkg/trace"
+	"context"
+	"log/slog"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// NewHandlerLog returns a slog.Handler that adds the request id, route and trace id of the context to the records of h
+// - so any code logging with the context of a request (controllers, storage) is traceable to the request
+// - records that already have a request_id, route or trace_id attribute keep theirs
+func NewHandlerLog(h slog.Handler) slog.Handler {
+	return &handlerLog{Handler: h}
+}
+
+// handlerLog is a slog.Handler that adds the request id, route and trace id of the context
+type handlerLog struct {
+	slog.Handler
+}
+
+func (h *handlerLog) Handle(ctx context.Context, rec slog.Record) error {
+	// present attributes
+	var hasID, hasRoute, hasTrace bool
+	rec.Attrs(func(a slog.Attr) bool {
+		hasID = hasID || a.Key == "request_id"
+		hasRoute = hasRoute || a.Key == "route"
+		hasTrace = hasTrace || a.Key == "trace_id"
+		return true
+	})
+
+	// context attributes
+	if id := RequestIDFrom(ctx); id != "" && !hasID {
+		rec.AddAttrs(slog.String("request_id", id))
+	}
+	if rctx := chi.RouteContext(ctx); rctx != nil && rctx.RoutePattern() != "" && !hasRoute {
+		rec.AddAttrs(slog.String("route", rctx.RoutePattern()))
+	}
+	if span := trace.SpanFromContext(ctx); span != nil && !hasTrace {
+		rec.AddAttrs(slog.String("trace_id", span.Context().TraceID.String()))
+	}
+
+	return h.Handler.Handle(ctx, rec)
+}
+
+func (h *handlerLog) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &handlerLog{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *handlerLog) WithGroup(name string) slog.Handler {
+	return &handlerLog{Handler: h.Handler.WithGroup(name)}
+}
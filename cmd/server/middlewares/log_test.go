@@ -0,0 +1,73 @@
+package middlewares
+
+import (
+	"app/pkg/trace"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+// Tests for NewHandlerLog function
+func TestNewHandlerLog(t *testing.T) {
+	t.Run("adds the request id and route of the context", func(t *testing.T) {
+		// arrange
+		var buf bytes.Buffer
+		logger := slog.New(NewHandlerLog(slog.NewJSONHandler(&buf, nil)))
+		r := chi.NewRouter()
+		r.Use(RequestID)
+		r.Get("/products/{id}", func(w http.ResponseWriter, r *http.Request) {
+			logger.With("component", "test").ErrorContext(r.Context(), "internal error", "error", "boom")
+		})
+		req := httptest.NewRequest(http.MethodGet, "/products/7", nil)
+		req.Header.Set(HeaderRequestID, "abc")
+
+		// act
+		r.ServeHTTP(httptest.NewRecorder(), req)
+
+		// assert
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+		require.Equal(t, "abc", entry["request_id"])
+		require.Equal(t, "/products/{id}", entry["route"])
+		require.Equal(t, "test", entry["component"])
+		require.Equal(t, "boom", entry["error"])
+	})
+
+	t.Run("keeps the attributes of the record", func(t *testing.T) {
+		// arrange
+		var buf bytes.Buffer
+		logger := slog.New(NewHandlerLog(slog.NewTextHandler(&buf, nil)))
+		hd := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			logger.InfoContext(r.Context(), "request", "request_id", "own")
+		}))
+
+		// act
+		hd.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+		// assert
+		require.Contains(t, buf.String(), "request_id=own")
+		require.Equal(t, 1, bytes.Count(buf.Bytes(), []byte("request_id=")))
+	})
+
+	t.Run("adds the trace id of the span of the context", func(t *testing.T) {
+		// arrange
+		var buf bytes.Buffer
+		logger := slog.New(NewHandlerLog(slog.NewJSONHandler(&buf, nil)))
+		ctx, span := trace.NewTracer(nil, nil).Start(context.Background(), "operation")
+
+		// act
+		logger.InfoContext(ctx, "message")
+
+		// assert
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+		require.Equal(t, span.Context().TraceID.String(), entry["trace_id"])
+	})
+}
@@ -0,0 +1,56 @@
+package middlewares
+
+import (
+	"app/pkg/metrics"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// routeUnmatched is the route label of the requests that matched no route, so unknown paths do not create series
+const routeUnmatched = "unmatched"
+
+// methodOther is the method label of the requests with a non standard method, so arbitrary methods do not create series
+const methodOther = "OTHER"
+
+// methodLabel returns the method label of a request method
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return methodOther
+}
+
+// Metrics counts the requests and observes their latency, labeled by method (OTHER if not standard), route pattern and status
+// - http_requests_total and http_request_duration_seconds are registered in registry
+func Metrics(registry *metrics.Registry) func(next http.Handler) http.Handler {
+	requests := registry.Counter("http_requests_total", "Requests served.", "method", "route", "status")
+	durations := registry.Histogram("http_request_duration_seconds", "Latency of the requests in seconds.", metrics.DefaultBuckets, "method", "route", "status")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rw := wrap(w)
+
+			defer func() {
+				route := routePattern(r)
+				if route == "" {
+					route = routeUnmatched
+				}
+				status := rw.status
+				if status == 0 {
+					// nothing written, net/http answers 200
+					status = http.StatusOK
+				}
+
+				method := methodLabel(r.Method)
+				requests.Inc(method, route, strconv.Itoa(status))
+				durations.Observe(time.Since(start).Seconds(), method, route, strconv.Itoa(status))
+			}()
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
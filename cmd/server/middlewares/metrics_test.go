@@ -0,0 +1,40 @@
+package middlewares
+
+import (
+	"app/pkg/metrics"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+// Tests for Metrics middleware
+func TestMetrics(t *testing.T) {
+	// arrange
+	registry := metrics.NewRegistry()
+	r := chi.NewRouter()
+	r.Use(Metrics(registry))
+	r.Get("/products/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	// act
+	for _, path := range []string{"/products/1", "/products/2", "/unknown"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	for _, method := range []string{"PURGE", "FOO"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/products/1", nil))
+	}
+
+	// assert
+	var b strings.Builder
+	require.NoError(t, registry.Write(&b))
+	require.Contains(t, b.String(), `http_requests_total{method="GET",route="/products/{id}",status="404"} 2`)
+	require.Contains(t, b.String(), `http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+	require.Contains(t, b.String(), `http_request_duration_seconds_count{method="GET",route="/products/{id}",status="404"} 2`)
+	require.Contains(t, b.String(), `http_requests_total{method="OTHER",route="unmatched",status="405"} 2`)
+	require.NotContains(t, b.String(), `PURGE`)
+}
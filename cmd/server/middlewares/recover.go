@@ -0,0 +1,55 @@
+package middlewares
+
+import (
+	"app/pkg/web/response"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+)
+
+// responseBodyError is the response body of the errors written by the middlewares, as the body of any other error
+type responseBodyError struct {
+	Message string	`json:"message"`
+	Data    any		`json:"data"`
+	Error   bool	`json:"error"`
+}
+
+// Recover recovers the panics of the handlers, logs them with their stack and responds 500 Internal Server Error
+// - if the response was already started it can not be replaced, the connection is aborted
+// - http.ErrAbortHandler is re-panicked, it is how handlers abort a response on purpose
+func Recover(logger *slog.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rw := wrap(w)
+
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+
+				logger.LogAttrs(r.Context(), slog.LevelError, "panic",
+					slog.String("request_id", RequestIDFrom(r.Context())),
+					slog.String("method", r.Method),
+					slog.String("route", routePattern(r)),
+					slog.Any("panic", rec),
+					slog.String("stack", string(debug.Stack())),
+				)
+
+				// response
+				if rw.status != 0 {
+					panic(http.ErrAbortHandler)
+				}
+				code := http.StatusInternalServerError
+				body := &responseBodyError{Message: "internal error", Data: nil, Error: true}
+
+				response.JSON(rw, code, body)
+			}()
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package middlewares
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Tests for Recover middleware
+func TestRecover(t *testing.T) {
+	t.Run("500 - panic before the response", func(t *testing.T) {
+		// arrange
+		var buf bytes.Buffer
+		hd := Recover(slog.New(slog.NewJSONHandler(&buf, nil)))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		}))
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		res := httptest.NewRecorder()
+
+		// act
+		hd.ServeHTTP(res, req)
+
+		// assert
+		require.Equal(t, http.StatusInternalServerError, res.Code)
+		require.JSONEq(t, `{"message":"internal error","data":null,"error":true}`, res.Body.String())
+		require.Contains(t, buf.String(), `"panic":"boom"`)
+		require.Contains(t, buf.String(), `"stack":`)
+	})
+
+	t.Run("abort - panic after the response started", func(t *testing.T) {
+		// arrange
+		hd := Recover(slog.New(slog.NewJSONHandler(io.Discard, nil)))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("partial"))
+			panic("boom")
+		}))
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		res := httptest.NewRecorder()
+
+		// act & assert
+		require.PanicsWithValue(t, http.ErrAbortHandler, func() { hd.ServeHTTP(res, req) })
+		require.Equal(t, "partial", res.Body.String())
+	})
+
+	t.Run("abort - http.ErrAbortHandler is re-panicked", func(t *testing.T) {
+		// arrange
+		var buf bytes.Buffer
+		hd := Recover(slog.New(slog.NewJSONHandler(&buf, nil)))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic(http.ErrAbortHandler)
+		}))
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		res := httptest.NewRecorder()
+
+		// act & assert
+		require.PanicsWithValue(t, http.ErrAbortHandler, func() { hd.ServeHTTP(res, req) })
+		require.Empty(t, buf.String())
+	})
+}
@@ -0,0 +1,59 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// HeaderRequestID is the header with the id of a request
+const HeaderRequestID = "X-Request-ID"
+
+// maxLenRequestID is the max length of a request id given by the client
+const maxLenRequestID = 128
+
+// keyRequestID is the context key of the request id
+type keyRequestID struct{}
+
+// RequestID sets the id of the request in its context and in the X-Request-ID response header
+// - the id given by the client in X-Request-ID is kept if it is printable ascii of at most 128 characters,
+//   otherwise a random id is generated
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(HeaderRequestID)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set(HeaderRequestID, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), keyRequestID{}, id)))
+	})
+}
+
+// RequestIDFrom returns the id of the request of ctx ("" if there is none)
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(keyRequestID{}).(string)
+	return id
+}
+
+// validRequestID reports whether a request id given by the client can be used
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxLenRequestID {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a random id of 32 hex characters
+func newRequestID() string {
+	b := make([]byte, 16)
+	// crypto/rand does not fail on the supported platforms
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,58 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Tests for RequestID middleware
+func TestRequestID(t *testing.T) {
+	type input struct { header string }
+	type output struct { kept bool }
+	type testCase struct {
+		name string
+		input input
+		output output
+	}
+
+	cases := []testCase{
+		// valid cases
+		{name: "kept", input: input{header: "3f2c-41a0"}, output: output{kept: true}},
+
+		// invalid cases
+		{name: "missing", input: input{header: ""}, output: output{kept: false}},
+		{name: "too long", input: input{header: strings.Repeat("a", 129)}, output: output{kept: false}},
+		{name: "not printable", input: input{header: "id with spaces"}, output: output{kept: false}},
+	}
+
+	// run tests
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// arrange
+			var id string
+			hd := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				id = RequestIDFrom(r.Context())
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if c.input.header != "" {
+				req.Header.Set(HeaderRequestID, c.input.header)
+			}
+			res := httptest.NewRecorder()
+
+			// act
+			hd.ServeHTTP(res, req)
+
+			// assert
+			require.Equal(t, id, res.Header().Get(HeaderRequestID))
+			if c.output.kept {
+				require.Equal(t, c.input.header, id)
+			} else {
+				require.Len(t, id, 32)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package middlewares
+
+import (
+	"app/pkg/trace"
+	"fmt"
+	"net/http"
+)
+
+// Trace starts a span for each request, child of the traceparent header of the request if it has a valid one
+// - the span is named and attributed once the request is served, when the route pattern is known
+// - responses with a 5xx status mark the span as failed
+func Trace(tracer *trace.Tracer) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if sc, ok := trace.ParseTraceparent(r.Header.Get(trace.HeaderTraceparent)); ok {
+				ctx = trace.ContextWithRemote(ctx, sc)
+			}
+			ctx, span := tracer.Start(ctx, "HTTP "+r.Method)
+			r = r.WithContext(ctx)
+			rw := wrap(w)
+
+			defer func() {
+				status := rw.status
+				if status == 0 {
+					// nothing written, net/http answers 200
+					status = http.StatusOK
+				}
+				route := routePattern(r)
+				if route != "" {
+					span.SetName("HTTP " + r.Method + " " + route)
+				}
+				span.SetAttr("http.method", r.Method)
+				span.SetAttr("http.route", route)
+				span.SetAttr("http.target", r.URL.RequestURI())
+				span.SetAttr("http.status_code", status)
+				span.SetAttr("request_id", RequestIDFrom(r.Context()))
+				if status >= http.StatusInternalServerError {
+					span.RecordError(fmt.Errorf("%d %s", status, http.StatusText(status)))
+				}
+				span.End()
+			}()
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package middlewares
+
+import (
+	"app/pkg/trace"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+// exporterRecorder is a trace.Exporter that keeps the exported spans
+type exporterRecorder struct {
+	spans []*trace.SpanData
+}
+
+func (e *exporterRecorder) Export(span *trace.SpanData) (err error) {
+	e.spans = append(e.spans, span)
+	return
+}
+
+// Tests for Trace middleware
+func TestTrace(t *testing.T) {
+	t.Run("child of the traceparent of the request", func(t *testing.T) {
+		// arrange
+		exporter := &exporterRecorder{}
+		r := chi.NewRouter()
+		r.Use(RequestID)
+		r.Use(Trace(trace.NewTracer(exporter, nil)))
+		var sc trace.SpanContext
+		r.Get("/products/{id}", func(w http.ResponseWriter, r *http.Request) {
+			sc, _ = trace.SpanContextFromContext(r.Context())
+			w.WriteHeader(http.StatusInternalServerError)
+		})
+		req := httptest.NewRequest(http.MethodGet, "/products/7?fields=name", nil)
+		req.Header.Set(trace.HeaderTraceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		req.Header.Set(HeaderRequestID, "abc")
+
+		// act
+		r.ServeHTTP(httptest.NewRecorder(), req)
+
+		// assert
+		require.Len(t, exporter.spans, 1)
+		span := exporter.spans[0]
+		require.Equal(t, "HTTP GET /products/{id}", span.Name)
+		require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.TraceID)
+		require.Equal(t, "00f067aa0ba902b7", span.ParentSpanID)
+		require.Equal(t, sc.SpanID.String(), span.SpanID)
+		require.Equal(t, map[string]any{
+			"http.method": "GET",
+			"http.route": "/products/{id}",
+			"http.target": "/products/7?fields=name",
+			"http.status_code": http.StatusInternalServerError,
+			"request_id": "abc",
+		}, span.Attributes)
+		require.Equal(t, "500 Internal Server Error", span.Error)
+	})
+
+	t.Run("invalid traceparent starts a new trace", func(t *testing.T) {
+		// arrange
+		exporter := &exporterRecorder{}
+		hd := Trace(trace.NewTracer(exporter, nil))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		req := httptest.NewRequest(http.MethodGet, "/unknown", nil)
+		req.Header.Set(trace.HeaderTraceparent, "00-invalid-01")
+
+		// act
+		hd.ServeHTTP(httptest.NewRecorder(), req)
+
+		// assert
+		require.Len(t, exporter.spans, 1)
+		require.Equal(t, "HTTP GET", exporter.spans[0].Name)
+		require.Equal(t, "", exporter.spans[0].ParentSpanID)
+		require.Equal(t, http.StatusOK, exporter.spans[0].Attributes["http.status_code"])
+		require.Equal(t, "", exporter.spans[0].Error)
+	})
+
+	t.Run("nil tracer", func(t *testing.T) {
+		// arrange
+		hd := Trace(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		w := httptest.NewRecorder()
+
+		// act
+		hd.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		// assert
+		require.Equal(t, http.StatusNoContent, w.Code)
+	})
+}
@@ -0,0 +1,53 @@
+package middlewares
+
+import (
+	"net/http"
+)
+
+// responseWriter is a http.ResponseWriter that records the status and size of the response
+type responseWriter struct {
+	http.ResponseWriter
+	// status is the status code written (0 until the header is written)
+	status	int
+	// bytes is the number of bytes of the body written
+	bytes	int
+}
+
+// wrap returns w as a *responseWriter, reusing it if a previous middleware already wrapped it
+func wrap(w http.ResponseWriter) *responseWriter {
+	if rw, ok := w.(*responseWriter); ok {
+		return rw
+	}
+	return &responseWriter{ResponseWriter: w}
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (n int, err error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err = w.ResponseWriter.Write(b)
+	w.bytes += n
+	return
+}
+
+// Flush sends the buffered data to the client, if the underlying writer supports it
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying writer, for http.ResponseController
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,164 @@
+// Package database opens the MySQL database of the application
+package database
+
+import (
+	"app/pkg/metrics"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+var (
+	ErrDatabaseInternal = errors.New("internal database error")
+	ErrDatabaseUnavailable = errors.New("database unavailable")
+)
+
+// Config is the configuration of the MySQL database, loaded by pkg/config
+type Config struct {
+	// User is the user of the database
+	User		string	`config:"user" env:"DB_MYSQL_USER" flag:"db-user" usage:"user of the database"`
+	// Password is the password of the user (it has no flag, so it does not show in the process list)
+	Password	string	`config:"password,secret" env:"DB_MYSQL_PASSWORD"`
+	// Addr is the host:port of the database
+	Addr		string	`config:"addr" env:"DB_MYSQL_ADDR" flag:"db-addr" usage:"host:port of the database"`
+	// Database is the name of the database
+	Database	string	`config:"database" env:"DB_MYSQL_DATABASE" flag:"db-database" usage:"name of the database"`
+	// pool (0 means no limit)
+	// -> MaxOpenConns is the max number of open connections
+	MaxOpenConns	int	`config:"max_open_conns" env:"DB_MYSQL_MAX_OPEN_CONNS" flag:"db-max-open-conns" usage:"max number of open connections (0 means no limit)"`
+	// -> MaxIdleConns is the max number of idle connections kept in the pool
+	MaxIdleConns	int	`config:"max_idle_conns" env:"DB_MYSQL_MAX_IDLE_CONNS" flag:"db-max-idle-conns" usage:"max number of idle connections"`
+	// -> ConnMaxLifetime is the max duration a connection is reused
+	ConnMaxLifetime	time.Duration	`config:"conn_max_lifetime" env:"DB_MYSQL_CONN_MAX_LIFETIME" flag:"db-conn-max-lifetime" usage:"max duration a connection is reused (0 means no limit)"`
+	// -> ConnMaxIdleTime is the max duration a connection stays idle
+	ConnMaxIdleTime	time.Duration	`config:"conn_max_idle_time" env:"DB_MYSQL_CONN_MAX_IDLE_TIME" flag:"db-conn-max-idle-time" usage:"max duration a connection stays idle (0 means no limit)"`
+	// startup
+	// -> PingAttempts is how many times the database is pinged before giving up (at least 1)
+	PingAttempts	int	`config:"ping_attempts" env:"DB_MYSQL_PING_ATTEMPTS" flag:"db-ping-attempts" usage:"times the database is pinged on start before giving up"`
+	// -> PingBackoff is the wait after the first failed ping, doubled after each failure
+	PingBackoff	time.Duration	`config:"ping_backoff" env:"DB_MYSQL_PING_BACKOFF" flag:"db-ping-backoff" usage:"wait after the first failed ping, doubled after each failure"`
+}
+
+// DefaultConfig returns the configuration used for the values that are not configured
+func DefaultConfig() *Config {
+	return &Config{
+		MaxOpenConns: 25,
+		MaxIdleConns: 25,
+		ConnMaxLifetime: 5 * time.Minute,
+		ConnMaxIdleTime: time.Minute,
+		PingAttempts: 5,
+		PingBackoff: 500 * time.Millisecond,
+	}
+}
+
+// Validate checks the required fields are set
+func (c *Config) Validate() (err error) {
+	var missing []string
+	if c.User == "" {
+		missing = append(missing, "user")
+	}
+	if c.Addr == "" {
+		missing = append(missing, "addr")
+	}
+	if c.Database == "" {
+		missing = append(missing, "database")
+	}
+	if len(missing) > 0 {
+		err = fmt.Errorf("database %s required", strings.Join(missing, ", "))
+		return
+	}
+	return
+}
+
+// MySQL returns the configuration of the driver
+// - parseTime is set, the storages and migrations scan DATETIME columns into time.Time
+func (c *Config) MySQL() *mysql.Config {
+	cfg := mysql.NewConfig()
+	cfg.User = c.User
+	cfg.Passwd = c.Password
+	cfg.Net = "tcp"
+	cfg.Addr = c.Addr
+	cfg.DBName = c.Database
+	cfg.ParseTime = true
+	return cfg
+}
+
+// Open returns the database pool of the configuration, once the database answers a ping
+// - the ping is retried PingAttempts times, waiting PingBackoff after the first failure and doubling it after each one
+// - ErrDatabaseUnavailable if every ping fails or ctx is done before the database answers
+func Open(ctx context.Context, c *Config) (db *sql.DB, err error) {
+	db, err = sql.Open("mysql", c.MySQL().FormatDSN())
+	if err != nil {
+		err = fmt.Errorf("%w. %v", ErrDatabaseInternal, err)
+		return
+	}
+
+	// pool
+	db.SetMaxOpenConns(c.MaxOpenConns)
+	db.SetMaxIdleConns(c.MaxIdleConns)
+	db.SetConnMaxLifetime(c.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(c.ConnMaxIdleTime)
+
+	// ping
+	err = ping(ctx, db, c.PingAttempts, c.PingBackoff)
+	if err != nil {
+		db.Close()
+		db = nil
+		return
+	}
+	return
+}
+
+// ping pings the database until it answers, at most attempts times
+func ping(ctx context.Context, db *sql.DB, attempts int, backoff time.Duration) (err error) {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		err = db.PingContext(ctx)
+		if err == nil {
+			return
+		}
+		if attempt == attempts {
+			err = fmt.Errorf("%w. %d attempts, last: %v", ErrDatabaseUnavailable, attempts, err)
+			return
+		}
+
+		// -> wait before the next attempt
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			err = fmt.Errorf("%w. %v, last: %v", ErrDatabaseUnavailable, ctx.Err(), err)
+			return
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// RegisterMetrics registers the statistics of the pool of db in registry, read when the metrics are written
+func RegisterMetrics(registry *metrics.Registry, db *sql.DB) {
+	stat := func(fn func(s sql.DBStats) float64) func() float64 {
+		return func() float64 { return fn(db.Stats()) }
+	}
+
+	// pool
+	registry.GaugeFunc("db_max_open_connections", "Max number of open connections to the database.", stat(func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }))
+	registry.GaugeFunc("db_open_connections", "Open connections to the database, in use and idle.", stat(func(s sql.DBStats) float64 { return float64(s.OpenConnections) }))
+	registry.GaugeFunc("db_in_use_connections", "Connections to the database in use.", stat(func(s sql.DBStats) float64 { return float64(s.InUse) }))
+	registry.GaugeFunc("db_idle_connections", "Idle connections to the database.", stat(func(s sql.DBStats) float64 { return float64(s.Idle) }))
+	// waits for a connection
+	registry.CounterFunc("db_wait_count_total", "Times a connection to the database was waited for.", stat(func(s sql.DBStats) float64 { return float64(s.WaitCount) }))
+	registry.CounterFunc("db_wait_duration_seconds_total", "Time waited for connections to the database in seconds.", stat(func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }))
+	// closed connections
+	registry.CounterFunc("db_max_idle_closed_total", "Connections closed due to the max idle connections.", stat(func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) }))
+	registry.CounterFunc("db_max_idle_time_closed_total", "Connections closed due to the max idle time.", stat(func(s sql.DBStats) float64 { return float64(s.MaxIdleTimeClosed) }))
+	registry.CounterFunc("db_max_lifetime_closed_total", "Connections closed due to the max lifetime.", stat(func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) }))
+}
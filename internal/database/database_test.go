@@ -0,0 +1,107 @@
+package database
+
+import (
+	"app/pkg/metrics"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// connectorFailing is a driver.Connector whose first connections fail
+type connectorFailing struct {
+	// failures is the number of connections that fail before one succeeds
+	failures int
+	// attempts counts the connections
+	attempts int
+}
+
+func (c *connectorFailing) Connect(ctx context.Context) (driver.Conn, error) {
+	c.attempts++
+	if c.attempts <= c.failures {
+		return nil, errors.New("connection refused")
+	}
+	return connStub{}, nil
+}
+
+func (c *connectorFailing) Driver() driver.Driver {
+	return nil
+}
+
+// connStub is a driver.Conn that does nothing
+type connStub struct{}
+
+func (connStub) Prepare(query string) (driver.Stmt, error) { return nil, errors.New("not implemented") }
+func (connStub) Close() error { return nil }
+func (connStub) Begin() (driver.Tx, error) { return nil, errors.New("not implemented") }
+
+// Tests for ping function
+func TestPing(t *testing.T) {
+	type input struct { failures int; attempts int; canceled bool }
+	type output struct { err error; attempts int }
+	type testCase struct {
+		name string
+		input input
+		output output
+	}
+
+	cases := []testCase{
+		// valid cases
+		{name: "first attempt", input: input{failures: 0, attempts: 3}, output: output{err: nil, attempts: 1}},
+		{name: "after retries", input: input{failures: 2, attempts: 3}, output: output{err: nil, attempts: 3}},
+
+		// invalid cases
+		{name: "every attempt fails", input: input{failures: 3, attempts: 3}, output: output{err: ErrDatabaseUnavailable, attempts: 3}},
+		{name: "at least one attempt", input: input{failures: 1, attempts: 0}, output: output{err: ErrDatabaseUnavailable, attempts: 1}},
+		{name: "canceled while waiting", input: input{failures: 3, attempts: 3, canceled: true}, output: output{err: ErrDatabaseUnavailable, attempts: 1}},
+	}
+
+	// run tests
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// arrange
+			connector := &connectorFailing{failures: c.input.failures}
+			db := sql.OpenDB(connector)
+			defer db.Close()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			backoff := time.Millisecond
+			if c.input.canceled {
+				backoff = time.Hour
+				time.AfterFunc(10*time.Millisecond, cancel)
+			}
+
+			// act
+			err := ping(ctx, db, c.input.attempts, backoff)
+
+			// assert
+			require.ErrorIs(t, err, c.output.err)
+			require.Equal(t, c.output.attempts, connector.attempts)
+		})
+	}
+}
+
+// Tests for RegisterMetrics function
+func TestRegisterMetrics(t *testing.T) {
+	// arrange
+	db := sql.OpenDB(&connectorFailing{})
+	defer db.Close()
+	db.SetMaxOpenConns(7)
+	require.NoError(t, db.Ping())
+	registry := metrics.NewRegistry()
+
+	// act
+	RegisterMetrics(registry, db)
+
+	// assert
+	var b strings.Builder
+	require.NoError(t, registry.Write(&b))
+	require.Contains(t, b.String(), "# TYPE db_max_open_connections gauge\ndb_max_open_connections 7\n")
+	require.Contains(t, b.String(), "# TYPE db_idle_connections gauge\ndb_idle_connections 1\n")
+	require.Contains(t, b.String(), "# TYPE db_wait_count_total counter\ndb_wait_count_total 0\n")
+}
@@ -0,0 +1,2 @@
+-- 0001 create_products (down)
+DROP TABLE products;
@@ -0,0 +1,14 @@
+-- 0001 create_products (up)
+-- - names are unique, compared case-insensitively by the collation
+-- - version and updated_at back the optimistic concurrency of the storage (ETag and Last-Modified)
+CREATE TABLE products (
+	id INT NOT NULL AUTO_INCREMENT,
+	name VARCHAR(255) NOT NULL,
+	type VARCHAR(255) NULL,
+	count INT NULL,
+	price DOUBLE NULL,
+	version INT NOT NULL DEFAULT 1,
+	updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+	PRIMARY KEY (id),
+	UNIQUE KEY uq_products_name (name)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_unicode_ci;
@@ -0,0 +1,10 @@
+// Package migrations has the sql migrations of the database, embedded in the binary
+// - the files are created with: go run ./cmd/migrate create <name>
+package migrations
+
+import "embed"
+
+// FS has the sql files of the migrations
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"app/internal/products/storage"
+	"context"
+	"errors"
+)
+
+// ServiceProduct is an interface for the business rules of products
+// - products are validated before they are stored
+// - validation errors are returned as *validator.ErrorValidatorProduct, listing every invalid field
+// - the other errors are the ErrServiceProduct errors
+type ServiceProduct interface {
+	// GetOne returns one product by id
+	// - ErrServiceProductNotFound if there is no product with the id
+	GetOne(ctx context.Context, id int) (p *storage.Product, err error)
+
+	// GetAll returns the products matching the query and the total count of matching products
+	// - ErrServiceProductQueryInvalid if the query sorts by an unknown field
+	GetAll(ctx context.Context, q *storage.QueryProduct) (ps []*storage.Product, total int, err error)
+
+	// Each calls fn with each product matching the filter, ordered by id, without loading them all at once
+	// - it stops at the first error returned by fn, which is returned as is
+	Each(ctx context.Context, f *storage.FilterProduct, fn func(p *storage.Product) (err error)) (err error)
+
+	// Validate returns the validation error of the product, without storing it
+	Validate(p *storage.Product) (err error)
+
+	// Store validates and stores product, setting its id, version and updated at
+	// - ErrServiceProductNotUnique if another product has the same name
+	Store(ctx context.Context, p *storage.Product) (err error)
+
+	// Update validates and replaces product, setting its new version and updated at
+	// - if p.Version is not 0 the product is only updated if it still has that version
+	// - if precondition is not nil the product is only updated if the current product satisfies it
+	// - ErrServiceProductNotFound, ErrServiceProductVersionMismatch, ErrServiceProductNotUnique
+	// - ErrServiceProductPreconditionFailed if the precondition fails or there is no product to check it on
+	Update(ctx context.Context, p *storage.Product, precondition Precondition) (err error)
+
+	// Patch applies the patch to the product by id, validates and updates it, and returns the updated product
+	// - the patch applies to the json document {"name", "type", "count", "price"} of the product,
+	//   fields removed by the patch are cleared
+	// - ErrServiceProductPatchInvalid if the patch is malformed or the patched document is not a product
+	// - ErrServiceProductPatchConflict if the patch can not be applied to the product
+	// - other errors as in Update
+	Patch(ctx context.Context, id int, format PatchFormat, patch []byte, precondition Precondition) (p *storage.Product, err error)
+
+	// Delete deletes product by id
+	// - ErrServiceProductNotFound if there is no product with the id
+	// - ErrServiceProductPreconditionFailed as in Update
+	Delete(ctx context.Context, id int, precondition Precondition) (err error)
+
+	// StoreMany validates and stores products like Store, all at once
+	// - errs has the error of each product (nil if it was stored)
+	// - storage.BatchModeAtomic: if any product is invalid or fails nothing is stored, err is the first failure
+	//   and the other products have ErrServiceProductRolledBack
+	StoreMany(ctx context.Context, ps []*storage.Product, mode storage.BatchMode) (errs []error, err error)
+
+	// UpdateMany validates and replaces products like Update (without precondition), all at once
+	// - errs and err as in StoreMany
+	UpdateMany(ctx context.Context, ps []*storage.Product, mode storage.BatchMode) (errs []error, err error)
+
+	// DeleteMany deletes products by id like Delete (without precondition), all at once
+	// - errs and err as in StoreMany
+	DeleteMany(ctx context.Context, ids []int, mode storage.BatchMode) (errs []error, err error)
+}
+
+// Precondition reports whether the current product satisfies the condition of an operation
+type Precondition func(current *storage.Product) bool
+
+// PatchFormat is the format of a patch
+type PatchFormat int
+
+const (
+	// PatchFormatMerge is a RFC 7396 JSON Merge Patch (null clears a field)
+	PatchFormatMerge PatchFormat = iota
+	// PatchFormatJSON is a RFC 6902 JSON Patch
+	PatchFormatJSON
+)
+
+var (
+	ErrServiceProductInternal = errors.New("internal service product error")
+	ErrServiceProductNotFound = errors.New("service product not found")
+	ErrServiceProductNotUnique = errors.New("service product not unique")
+	ErrServiceProductVersionMismatch = errors.New("service product version mismatch")
+	ErrServiceProductPreconditionFailed = errors.New("service product precondition failed")
+	ErrServiceProductQueryInvalid = errors.New("service product query invalid")
+	ErrServiceProductPatchInvalid = errors.New("service product patch invalid")
+	ErrServiceProductPatchConflict = errors.New("service product patch conflict")
+	ErrServiceProductRolledBack = errors.New("service product rolled back")
+)
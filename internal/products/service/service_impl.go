@@ -0,0 +1,377 @@
+package service
+
+import (
+	"app/internal/products/storage"
+	"app/internal/products/validator"
+	"app/pkg/jsonpatch"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// NewImplServiceProduct returns new ImplServiceProduct
+func NewImplServiceProduct(storage storage.StorageProduct, validator validator.ValidatorProduct) *ImplServiceProduct {
+	return &ImplServiceProduct{storage: storage, validator: validator}
+}
+
+// ImplServiceProduct is an implementation of ServiceProduct backed by a StorageProduct
+type ImplServiceProduct struct {
+	// storage is a storage for products
+	storage storage.StorageProduct
+	// validator validates products before storing them
+	validator validator.ValidatorProduct
+}
+
+// GetOne returns one product by id
+func (s *ImplServiceProduct) GetOne(ctx context.Context, id int) (p *storage.Product, err error) {
+	p, err = s.storage.GetOne(ctx, id)
+	if err != nil {
+		err = errService(err, false)
+		return
+	}
+
+	return
+}
+
+// GetAll returns the products matching the query and the total count of matching products
+func (s *ImplServiceProduct) GetAll(ctx context.Context, q *storage.QueryProduct) (ps []*storage.Product, total int, err error) {
+	ps, total, err = s.storage.GetAll(ctx, q)
+	if err != nil {
+		err = errService(err, false)
+		return
+	}
+
+	return
+}
+
+// Each calls fn with each product matching the filter, ordered by id
+func (s *ImplServiceProduct) Each(ctx context.Context, f *storage.FilterProduct, fn func(p *storage.Product) (err error)) (err error) {
+	var errFn error
+	err = s.storage.Each(ctx, f, func(p *storage.Product) error {
+		errFn = fn(p)
+		return errFn
+	})
+	if err != nil && err != errFn {
+		err = errService(err, false)
+		return
+	}
+
+	return
+}
+
+// Validate returns the validation error of the product
+func (s *ImplServiceProduct) Validate(p *storage.Product) (err error) {
+	return s.validator.Validate(p)
+}
+
+// Store validates and stores product
+func (s *ImplServiceProduct) Store(ctx context.Context, p *storage.Product) (err error) {
+	// validation
+	err = s.validator.Validate(p)
+	if err != nil {
+		return
+	}
+
+	// store
+	err = s.storage.Store(ctx, p)
+	if err != nil {
+		err = errService(err, false)
+		return
+	}
+
+	return
+}
+
+// Update validates and replaces product
+// - with a precondition the current product is read and updated in one transaction
+func (s *ImplServiceProduct) Update(ctx context.Context, p *storage.Product, precondition Precondition) (err error) {
+	// validation
+	err = s.validator.Validate(p)
+	if err != nil {
+		return
+	}
+
+	// update
+	if precondition == nil {
+		err = s.storage.Update(ctx, p)
+	} else {
+		err = s.storage.WithTx(ctx, func(tx storage.StorageProduct) (err error) {
+			// -> check precondition
+			current, err := tx.GetOne(ctx, (*p).ID)
+			if err != nil {
+				return
+			}
+			if !precondition(current) {
+				err = ErrServiceProductPreconditionFailed
+				return
+			}
+			if (*p).Version == 0 {
+				(*p).Version = current.Version
+			}
+
+			// -> update product
+			return tx.Update(ctx, p)
+		})
+	}
+	if err != nil {
+		err = errService(err, precondition != nil)
+		return
+	}
+
+	return
+}
+
+// documentProduct is the json document of a product that patches apply to
+type documentProduct struct {
+	Name    string	`json:"name"`
+	Type	*string	`json:"type"`
+	Count	*int	`json:"count"`
+	Price	*float64	`json:"price"`
+}
+
+// Patch applies the patch to the product by id, validates and updates it
+// - the product is read, patched and updated in one transaction, the update is conditioned on the patched version
+func (s *ImplServiceProduct) Patch(ctx context.Context, id int, format PatchFormat, patch []byte, precondition Precondition) (p *storage.Product, err error) {
+	var apply func(doc []byte, patch []byte) (patched []byte, err error)
+	switch format {
+	case PatchFormatMerge:
+		apply = jsonpatch.MergePatch
+	case PatchFormatJSON:
+		apply = jsonpatch.Apply
+	default:
+		err = fmt.Errorf("%w. unknown format %d", ErrServiceProductPatchInvalid, format)
+		return
+	}
+
+	err = s.storage.WithTx(ctx, func(tx storage.StorageProduct) (err error) {
+		// -> get searched product by id
+		current, err := tx.GetOne(ctx, id)
+		if err != nil {
+			return
+		}
+		// -- check precondition
+		if precondition != nil && !precondition(current) {
+			err = ErrServiceProductPreconditionFailed
+			return
+		}
+
+		// -> apply patch
+		p, err = patchProduct(current, apply, patch)
+		if err != nil {
+			return
+		}
+
+		// -> validation
+		err = s.validator.Validate(p)
+		if err != nil {
+			return
+		}
+
+		// -> update product
+		return tx.Update(ctx, p)
+	})
+	if err != nil {
+		p = nil
+		err = errService(err, precondition != nil)
+		return
+	}
+
+	return
+}
+
+// patchProduct returns the product resulting from applying the patch to the document of the product
+// - the patched product keeps the id and version of the product
+func patchProduct(current *storage.Product, apply func(doc []byte, patch []byte) (patched []byte, err error), patch []byte) (p *storage.Product, err error) {
+	// serialization
+	doc, err := json.Marshal(&documentProduct{
+		Name:	current.Name,
+		Type:	current.Type,
+		Count:	current.Count,
+		Price:	current.Price,
+	})
+	if err != nil {
+		err = fmt.Errorf("%w. %v", ErrServiceProductInternal, err)
+		return
+	}
+
+	// apply patch
+	patched, err := apply(doc, patch)
+	if err != nil {
+		switch {
+		case errors.Is(err, jsonpatch.ErrPatchConflict):
+			err = fmt.Errorf("%w. %v", ErrServiceProductPatchConflict, err)
+		default:
+			err = fmt.Errorf("%w. %v", ErrServiceProductPatchInvalid, err)
+		}
+		return
+	}
+
+	// deserialization (fields removed by the patch are cleared)
+	var product documentProduct
+	dec := json.NewDecoder(bytes.NewReader(patched))
+	dec.DisallowUnknownFields()
+	err = dec.Decode(&product)
+	if err != nil {
+		err = fmt.Errorf("%w. %v", ErrServiceProductPatchInvalid, err)
+		return
+	}
+
+	p = &storage.Product{
+		ID:		current.ID,
+		Name:   product.Name,
+		Type:	product.Type,
+		Count:	product.Count,
+		Price:	product.Price,
+		Version: current.Version,	// the patch was applied to this version
+	}
+	return
+}
+
+// Delete deletes product by id
+// - with a precondition the current product is read and deleted in one transaction
+func (s *ImplServiceProduct) Delete(ctx context.Context, id int, precondition Precondition) (err error) {
+	if precondition == nil {
+		err = s.storage.Delete(ctx, id, 0)
+	} else {
+		err = s.storage.WithTx(ctx, func(tx storage.StorageProduct) (err error) {
+			// -> check precondition
+			current, err := tx.GetOne(ctx, id)
+			if err != nil {
+				return
+			}
+			if !precondition(current) {
+				err = ErrServiceProductPreconditionFailed
+				return
+			}
+
+			// -> delete product by id
+			return tx.Delete(ctx, id, current.Version)
+		})
+	}
+	if err != nil {
+		err = errService(err, precondition != nil)
+		return
+	}
+
+	return
+}
+
+// StoreMany validates and stores products like Store, all at once
+func (s *ImplServiceProduct) StoreMany(ctx context.Context, ps []*storage.Product, mode storage.BatchMode) (errs []error, err error) {
+	return s.batch(ps, mode, func(valid []*storage.Product) (errs []error, err error) {
+		return s.storage.StoreMany(ctx, valid, mode)
+	})
+}
+
+// UpdateMany validates and replaces products like Update, all at once
+func (s *ImplServiceProduct) UpdateMany(ctx context.Context, ps []*storage.Product, mode storage.BatchMode) (errs []error, err error) {
+	return s.batch(ps, mode, func(valid []*storage.Product) (errs []error, err error) {
+		return s.storage.UpdateMany(ctx, valid, mode)
+	})
+}
+
+// DeleteMany deletes products by id like Delete, all at once
+func (s *ImplServiceProduct) DeleteMany(ctx context.Context, ids []int, mode storage.BatchMode) (errs []error, err error) {
+	errs, err = s.storage.DeleteMany(ctx, ids, mode)
+	if err != nil && len(errs) != len(ids) {
+		// the storage failed as a whole
+		err = errService(err, false)
+		errs = make([]error, len(ids))
+		for i := range errs {
+			errs[i] = err
+		}
+		return
+	}
+	for i := range errs {
+		errs[i] = errService(errs[i], false)
+	}
+	err = errService(err, false)
+	return
+}
+
+// batch validates the products of a batch and applies the valid ones
+// - storage.BatchModeAtomic: nothing is applied if any product is invalid
+func (s *ImplServiceProduct) batch(ps []*storage.Product, mode storage.BatchMode, apply func(valid []*storage.Product) (errs []error, err error)) (errs []error, err error) {
+	errs = make([]error, len(ps))
+
+	// validation
+	var indexes []int
+	var valid []*storage.Product
+	for i, p := range ps {
+		errs[i] = s.validator.Validate(p)
+		if errs[i] != nil {
+			continue
+		}
+		indexes = append(indexes, i)
+		valid = append(valid, p)
+	}
+	if len(valid) < len(ps) && mode == storage.BatchModeAtomic {
+		err = abortBatch(errs)
+		return
+	}
+	if len(valid) == 0 {
+		return
+	}
+
+	// apply
+	errsValid, err := apply(valid)
+	if err != nil && len(errsValid) != len(valid) {
+		// the storage failed as a whole
+		err = errService(err, false)
+		for _, i := range indexes {
+			errs[i] = err
+		}
+		return
+	}
+	for j, i := range indexes {
+		errs[i] = errService(errsValid[j], false)
+	}
+	err = errService(err, false)
+	return
+}
+
+// abortBatch marks the products of an aborted atomic batch that did not fail as rolled back
+// and returns the first failure
+func abortBatch(errs []error) (err error) {
+	for i, e := range errs {
+		if e == nil {
+			errs[i] = ErrServiceProductRolledBack
+			continue
+		}
+		if err == nil {
+			err = e
+		}
+	}
+	return
+}
+
+// errService returns the service error of an error of the storage (nil if err is nil)
+// - validation and service errors are returned as is
+// - with a precondition a missing product or another version fail the precondition
+func errService(err error, precondition bool) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, validator.ErrValidatorProductInvalid),
+		errors.Is(err, ErrServiceProductPreconditionFailed),
+		errors.Is(err, ErrServiceProductPatchInvalid),
+		errors.Is(err, ErrServiceProductPatchConflict),
+		errors.Is(err, ErrServiceProductInternal):
+		return err
+	case precondition && (errors.Is(err, storage.ErrStorageProductNotFound) || errors.Is(err, storage.ErrStorageProductVersionMismatch)):
+		return fmt.Errorf("%w. %v", ErrServiceProductPreconditionFailed, err)
+	case errors.Is(err, storage.ErrStorageProductNotFound):
+		return fmt.Errorf("%w. %v", ErrServiceProductNotFound, err)
+	case errors.Is(err, storage.ErrStorageProductNotUnique):
+		return fmt.Errorf("%w. %v", ErrServiceProductNotUnique, err)
+	case errors.Is(err, storage.ErrStorageProductVersionMismatch):
+		return fmt.Errorf("%w. %v", ErrServiceProductVersionMismatch, err)
+	case errors.Is(err, storage.ErrStorageProductQueryInvalid):
+		return fmt.Errorf("%w. %v", ErrServiceProductQueryInvalid, err)
+	case errors.Is(err, storage.ErrStorageProductRolledBack):
+		return fmt.Errorf("%w. %v", ErrServiceProductRolledBack, err)
+	}
+	return fmt.Errorf("%w. %v", ErrServiceProductInternal, err)
+}
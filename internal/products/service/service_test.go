@@ -0,0 +1,224 @@
+package service
+
+import (
+	"app/internal/products/storage"
+	"app/internal/products/validator"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newServiceProduct returns a service backed by an in-memory storage with one product (id 1, version 1)
+func newServiceProduct() *ImplServiceProduct {
+	typ := "fruit"
+	count := 3
+	price := 1.5
+	st := storage.NewImplStorageProductMap(map[int]*storage.Product{
+		1: {ID: 1, Name: "apple", Type: &typ, Count: &count, Price: &price, Version: 1},
+	})
+	vl := validator.NewImplValidatorProduct(nil)
+	return NewImplServiceProduct(st, vl)
+}
+
+// precondition returns a precondition that checks the version of the product
+func precondition(version int) Precondition {
+	return func(current *storage.Product) bool { return current.Version == version }
+}
+
+// Tests for ImplServiceProduct.Store method
+func TestImplServiceProduct_Store(t *testing.T) {
+	type input struct { p *storage.Product }
+	type output struct { err error; id int }
+	type testCase struct {
+		name string
+		input input
+		output output
+	}
+
+	cases := []testCase{
+		// valid cases
+		{name: "stored", input: input{p: &storage.Product{Name: "pear"}}, output: output{err: nil, id: 2}},
+
+		// invalid cases
+		{name: "invalid", input: input{p: &storage.Product{Name: ""}}, output: output{err: validator.ErrValidatorProductInvalid}},
+		{name: "not unique", input: input{p: &storage.Product{Name: "APPLE"}}, output: output{err: ErrServiceProductNotUnique}},
+	}
+
+	// run tests
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// arrange
+			sv := newServiceProduct()
+
+			// act
+			err := sv.Store(context.Background(), c.input.p)
+
+			// assert
+			require.ErrorIs(t, err, c.output.err)
+			require.Equal(t, c.output.id, c.input.p.ID)
+		})
+	}
+}
+
+// Tests for ImplServiceProduct.Update method
+func TestImplServiceProduct_Update(t *testing.T) {
+	type input struct { p *storage.Product; precondition Precondition }
+	type output struct { err error; version int }
+	type testCase struct {
+		name string
+		input input
+		output output
+	}
+
+	cases := []testCase{
+		// valid cases
+		{name: "without precondition", input: input{p: &storage.Product{ID: 1, Name: "pear"}}, output: output{err: nil, version: 2}},
+		{name: "precondition satisfied", input: input{p: &storage.Product{ID: 1, Name: "pear"}, precondition: precondition(1)}, output: output{err: nil, version: 2}},
+
+		// invalid cases
+		{name: "invalid", input: input{p: &storage.Product{ID: 1, Name: ""}}, output: output{err: validator.ErrValidatorProductInvalid}},
+		{name: "not found", input: input{p: &storage.Product{ID: 2, Name: "pear"}}, output: output{err: ErrServiceProductNotFound}},
+		{name: "version mismatch", input: input{p: &storage.Product{ID: 1, Name: "pear", Version: 2}}, output: output{err: ErrServiceProductVersionMismatch, version: 2}},
+		{name: "precondition failed", input: input{p: &storage.Product{ID: 1, Name: "pear"}, precondition: precondition(2)}, output: output{err: ErrServiceProductPreconditionFailed}},
+		{name: "precondition on a missing product", input: input{p: &storage.Product{ID: 2, Name: "pear"}, precondition: precondition(1)}, output: output{err: ErrServiceProductPreconditionFailed}},
+	}
+
+	// run tests
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// arrange
+			sv := newServiceProduct()
+
+			// act
+			err := sv.Update(context.Background(), c.input.p, c.input.precondition)
+
+			// assert
+			require.ErrorIs(t, err, c.output.err)
+			require.Equal(t, c.output.version, c.input.p.Version)
+		})
+	}
+}
+
+// Tests for ImplServiceProduct.Patch method
+func TestImplServiceProduct_Patch(t *testing.T) {
+	count := func(i int) *int { return &i }
+
+	type input struct { format PatchFormat; patch string; precondition Precondition }
+	type output struct { err error; p *storage.Product }
+	type testCase struct {
+		name string
+		input input
+		output output
+	}
+
+	cases := []testCase{
+		// valid cases
+		{
+			name: "merge patch clears a field",
+			input: input{format: PatchFormatMerge, patch: `{"count":5,"type":null,"price":null}`},
+			output: output{err: nil, p: &storage.Product{ID: 1, Name: "apple", Count: count(5), Version: 2}},
+		},
+		{
+			name: "json patch with precondition",
+			input: input{format: PatchFormatJSON, patch: `[{"op":"test","path":"/name","value":"apple"},{"op":"remove","path":"/type"},{"op":"remove","path":"/price"}]`, precondition: precondition(1)},
+			output: output{err: nil, p: &storage.Product{ID: 1, Name: "apple", Count: count(3), Version: 2}},
+		},
+
+		// invalid cases
+		{name: "invalid patch", input: input{format: PatchFormatMerge, patch: `{`}, output: output{err: ErrServiceProductPatchInvalid}},
+		{name: "unknown field", input: input{format: PatchFormatMerge, patch: `{"color":"red"}`}, output: output{err: ErrServiceProductPatchInvalid}},
+		{name: "conflict", input: input{format: PatchFormatJSON, patch: `[{"op":"test","path":"/name","value":"pear"}]`}, output: output{err: ErrServiceProductPatchConflict}},
+		{name: "patched product is invalid", input: input{format: PatchFormatMerge, patch: `{"count":-1}`}, output: output{err: validator.ErrValidatorProductInvalid}},
+		{name: "precondition failed", input: input{format: PatchFormatMerge, patch: `{}`, precondition: precondition(2)}, output: output{err: ErrServiceProductPreconditionFailed}},
+	}
+
+	// run tests
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// arrange
+			sv := newServiceProduct()
+
+			// act
+			p, err := sv.Patch(context.Background(), 1, c.input.format, []byte(c.input.patch), c.input.precondition)
+
+			// assert
+			require.ErrorIs(t, err, c.output.err)
+			if p != nil {
+				p.UpdatedAt = c.output.p.UpdatedAt
+			}
+			require.Equal(t, c.output.p, p)
+		})
+	}
+}
+
+// Tests for ImplServiceProduct.Delete method
+func TestImplServiceProduct_Delete(t *testing.T) {
+	type input struct { id int; precondition Precondition }
+	type output struct { err error }
+	type testCase struct {
+		name string
+		input input
+		output output
+	}
+
+	cases := []testCase{
+		// valid cases
+		{name: "without precondition", input: input{id: 1}, output: output{err: nil}},
+		{name: "precondition satisfied", input: input{id: 1, precondition: precondition(1)}, output: output{err: nil}},
+
+		// invalid cases
+		{name: "not found", input: input{id: 2}, output: output{err: ErrServiceProductNotFound}},
+		{name: "precondition failed", input: input{id: 1, precondition: precondition(2)}, output: output{err: ErrServiceProductPreconditionFailed}},
+	}
+
+	// run tests
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// arrange
+			sv := newServiceProduct()
+
+			// act
+			err := sv.Delete(context.Background(), c.input.id, c.input.precondition)
+
+			// assert
+			require.ErrorIs(t, err, c.output.err)
+		})
+	}
+}
+
+// Tests for ImplServiceProduct.StoreMany method
+func TestImplServiceProduct_StoreMany(t *testing.T) {
+	t.Run("atomic with an invalid product stores nothing", func(t *testing.T) {
+		// arrange
+		sv := newServiceProduct()
+		ps := []*storage.Product{{Name: "pear"}, {Name: ""}}
+
+		// act
+		errs, err := sv.StoreMany(context.Background(), ps, storage.BatchModeAtomic)
+
+		// assert
+		require.ErrorIs(t, err, validator.ErrValidatorProductInvalid)
+		require.ErrorIs(t, errs[0], ErrServiceProductRolledBack)
+		require.ErrorIs(t, errs[1], validator.ErrValidatorProductInvalid)
+		_, total, err := sv.GetAll(context.Background(), &storage.QueryProduct{Limit: 10})
+		require.NoError(t, err)
+		require.Equal(t, 1, total)
+	})
+
+	t.Run("best effort stores the valid products", func(t *testing.T) {
+		// arrange
+		sv := newServiceProduct()
+		ps := []*storage.Product{{Name: ""}, {Name: "pear"}, {Name: "apple"}}
+
+		// act
+		errs, err := sv.StoreMany(context.Background(), ps, storage.BatchModeBestEffort)
+
+		// assert
+		require.NoError(t, err)
+		require.ErrorIs(t, errs[0], validator.ErrValidatorProductInvalid)
+		require.NoError(t, errs[1])
+		require.Equal(t, 2, ps[1].ID)
+		require.ErrorIs(t, errs[2], ErrServiceProductNotUnique)
+	})
+}
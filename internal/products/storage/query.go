@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// QueryProduct is a query spec used to list products
+type QueryProduct struct {
+	// Filter restricts the products returned
+	Filter FilterProduct
+	// Sort orders the products returned (applied in order, id is always the last tie-breaker)
+	Sort []SortProduct
+	// Limit is the max amount of products returned
+	Limit int
+	// Offset is the amount of products skipped
+	Offset int
+}
+
+// FilterProduct is a set of filters for products (nil or empty values are not applied)
+type FilterProduct struct {
+	// Type matches products with exactly this type
+	Type *string
+	// MinPrice and MaxPrice match products with a price in the range (inclusive)
+	MinPrice *float64
+	MaxPrice *float64
+	// MinCount and MaxCount match products with a count in the range (inclusive)
+	MinCount *int
+	MaxCount *int
+	// Name matches products whose name contains this substring
+	Name string
+}
+
+// FieldProduct is a product field that can be used to sort products
+type FieldProduct string
+
+const (
+	FieldProductID    FieldProduct = "id"
+	FieldProductName  FieldProduct = "name"
+	FieldProductType  FieldProduct = "type"
+	FieldProductCount FieldProduct = "count"
+	FieldProductPrice FieldProduct = "price"
+)
+
+// FieldsProduct is the allowlist of fields that can be used to sort products
+var FieldsProduct = []FieldProduct{FieldProductID, FieldProductName, FieldProductType, FieldProductCount, FieldProductPrice}
+
+// SortProduct is a sort criteria for products
+type SortProduct struct {
+	// Field is the field to sort by
+	Field FieldProduct
+	// Desc sorts in descending order
+	Desc bool
+}
+
+var (
+	ErrStorageProductQueryInvalid = errors.New("storage product query invalid")
+)
+
+// ParseSortProduct parses a comma separated list of fields into sort criteria
+// - a field prefixed with "-" is sorted in descending order (example: "type,-price")
+func ParseSortProduct(s string) (sorts []SortProduct, err error) {
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+
+		var sort SortProduct
+		if strings.HasPrefix(f, "-") {
+			sort.Desc = true
+			f = f[1:]
+		}
+
+		sort.Field, err = ParseFieldProduct(f)
+		if err != nil {
+			sorts = nil
+			return
+		}
+
+		sorts = append(sorts, sort)
+	}
+
+	return
+}
+
+// ParseFieldProduct returns the allowlisted field matching s
+func ParseFieldProduct(s string) (f FieldProduct, err error) {
+	for _, field := range FieldsProduct {
+		if string(field) == s {
+			f = field
+			return
+		}
+	}
+
+	err = fmt.Errorf("%w. unknown field %q", ErrStorageProductQueryInvalid, s)
+	return
+}
@@ -0,0 +1,28 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Tests for ParseSortProduct function
+func TestParseSortProduct(t *testing.T) {
+	t.Run("ascending and descending fields", func(t *testing.T) {
+		// act
+		sorts, err := ParseSortProduct("type, -price")
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, []SortProduct{{Field: FieldProductType}, {Field: FieldProductPrice, Desc: true}}, sorts)
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		// act
+		sorts, err := ParseSortProduct("price,created_at")
+
+		// assert
+		require.ErrorIs(t, err, ErrStorageProductQueryInvalid)
+		require.Nil(t, sorts)
+	})
+}
@@ -1,33 +1,124 @@
 package storage
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"time"
+)
 
 // Product is a product model
+// - optional fields are pointers: nil means unknown (NULL), which is not the same as a zero value
+// - version starts at 1 and is incremented on each update (optimistic concurrency)
+// - updated at is set by the storage on each store and update (UTC, microsecond precision)
 type Product struct {
 	ID		int
 	Name    string
-	Type	string
-	Count	int
-	Price	float64
+	Type	*string
+	Count	*int
+	Price	*float64
+	Version	int
+	UpdatedAt	time.Time
 }
 
 // StorageProduct is an interface for product storage
+// - product names are unique
+// - every method takes the context of the request, its cancellation or deadline aborts the operation
 type StorageProduct interface {
 	// GetOne returns one product by id
-	GetOne(id int) (p *Product, err error)
+	// - ErrStorageProductNotFound if there is no product with the id
+	GetOne(ctx context.Context, id int) (p *Product, err error)
 
-	// Store stores product
-	Store(p *Product) (err error)
+	// GetAll returns the products matching the query and the total count of matching products
+	GetAll(ctx context.Context, q *QueryProduct) (ps []*Product, total int, err error)
 
-	// Update updates product
-	Update(p *Product) (err error)
+	// Each calls fn with each product matching the filter, ordered by id, without loading them all at once
+	// - it stops at the first error returned by fn, which is returned as is
+	Each(ctx context.Context, f *FilterProduct, fn func(p *Product) (err error)) (err error)
+
+	// Store stores product and sets its auto-increment id, its first version and its updated at
+	// - ErrStorageProductNotUnique if another product has the same name
+	Store(ctx context.Context, p *Product) (err error)
+
+	// Update updates product and sets its new version and its updated at
+	// - if p.Version is not 0 the product is only updated if it still has that version
+	// - ErrStorageProductNotFound if there is no product with the id
+	// - ErrStorageProductVersionMismatch if the product has another version
+	// - ErrStorageProductNotUnique if another product has the same name
+	Update(ctx context.Context, p *Product) (err error)
 
 	// Delete deletes product by id
-	Delete(id int) (err error)
+	// - if version is not 0 the product is only deleted if it still has that version
+	// - ErrStorageProductNotFound if there is no product with the id (deleting twice fails)
+	// - ErrStorageProductVersionMismatch if the product has another version
+	Delete(ctx context.Context, id int, version int) (err error)
+
+	// StoreMany stores products like Store, in one transaction
+	// - errs has the error of each product (nil if it was stored)
+	// - BatchModeAtomic: if any product fails nothing is stored, err is the first failure
+	//   and the products that did not fail have ErrStorageProductRolledBack
+	//   (if the batch fails as a whole, e.g. on commit, every product has err)
+	StoreMany(ctx context.Context, ps []*Product, mode BatchMode) (errs []error, err error)
+
+	// UpdateMany updates products like Update, in one transaction
+	// - errs and err as in StoreMany
+	UpdateMany(ctx context.Context, ps []*Product, mode BatchMode) (errs []error, err error)
+
+	// DeleteMany deletes products by id like Delete (without version), in one transaction
+	// - errs and err as in StoreMany
+	DeleteMany(ctx context.Context, ids []int, mode BatchMode) (errs []error, err error)
+
+	// WithTx runs fn with a storage bound to a transaction, so the operations of tx are applied atomically
+	// - the transaction is committed if fn returns nil, otherwise it is rolled back and the error of fn is returned
+	// - fn must only use tx (the storage may be locked until fn returns) and tx must not be used after fn returns
+	// - GetOne of tx locks the product until the transaction ends, so a read-modify-write is not racy
+	// - WithTx of tx nests a transaction that only rolls back its own operations
+	WithTx(ctx context.Context, fn func(tx StorageProduct) (err error)) (err error)
 }
 
+// BatchMode is how a batch of operations handles the failure of some of them
+type BatchMode int
+
+const (
+	// BatchModeAtomic applies every operation or none of them
+	BatchModeAtomic BatchMode = iota
+	// BatchModeBestEffort applies every operation that does not fail
+	BatchModeBestEffort
+)
+
 var (
 	ErrStorageProductInternal = errors.New("internal storage product error")
 	ErrStorageProductNotFound = errors.New("storage product not found")
 	ErrStorageProductNotUnique = errors.New("storage product not unique")
-)
\ No newline at end of file
+	ErrStorageProductVersionMismatch = errors.New("storage product version mismatch")
+	ErrStorageProductRolledBack = errors.New("storage product rolled back")
+)
+// abortBatch marks the operations of an aborted atomic batch that did not fail as rolled back
+// and returns the first failure
+// - if no operation failed on its own, the batch failed as a whole with cause, so every operation fails with it
+func abortBatch(errs []error, cause error) (err error) {
+	for _, e := range errs {
+		if e != nil {
+			err = e
+			break
+		}
+	}
+	if err == nil {
+		for i := range errs {
+			errs[i] = cause
+		}
+		err = cause
+		return
+	}
+
+	for i, e := range errs {
+		if e == nil {
+			errs[i] = ErrStorageProductRolledBack
+		}
+	}
+	return
+}
+
+// now returns the current time as stored by the storages (UTC, microsecond precision)
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
@@ -0,0 +1,56 @@
+package storage_test
+
+import (
+	"app/internal/migrations"
+	"app/internal/products/storage"
+	"app/internal/products/storage/storagetest"
+	"app/pkg/metrics"
+	"app/pkg/migrate"
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/require"
+)
+
+// Tests for ImplStorageProductMap against the StorageProduct contract
+func TestImplStorageProductMap(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.StorageProduct {
+		return storage.NewImplStorageProductMap(nil)
+	})
+}
+
+// Tests for ImplStorageProductMetrics against the StorageProduct contract
+func TestImplStorageProductMetrics(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.StorageProduct {
+		return storage.NewImplStorageProductMetrics(storage.NewImplStorageProductMap(nil), metrics.NewRegistry())
+	})
+}
+
+// Tests for ImplStorageProductMySQL against the StorageProduct contract
+// - runs only when DB_MYSQL_TEST_DSN points to a database (with parseTime=true)
+// - the migrations are applied and the products table is truncated on each test
+func TestImplStorageProductMySQL(t *testing.T) {
+	dsn := os.Getenv("DB_MYSQL_TEST_DSN")
+	if dsn == "" {
+		t.Skip("DB_MYSQL_TEST_DSN not set")
+	}
+
+	db, err := sql.Open("mysql", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	ms, err := migrate.Load(migrations.FS)
+	require.NoError(t, err)
+	_, err = migrate.NewMigrator(db, ms).Up(context.Background())
+	require.NoError(t, err)
+
+	storagetest.Run(t, func(t *testing.T) storage.StorageProduct {
+		_, err := db.Exec("TRUNCATE TABLE products")
+		require.NoError(t, err)
+
+		return storage.NewImplStorageProductMySQL(db, nil)
+	})
+}
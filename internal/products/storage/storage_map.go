@@ -0,0 +1,530 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// NewImplStorageProductMap returns new ImplStorageProductMap
+// - db can be nil, in that case an empty map is used
+func NewImplStorageProductMap(db map[int]*Product) *ImplStorageProductMap {
+	// default config
+	defaultDb := make(map[int]*Product)
+	defaultLastID := 0
+	if db != nil {
+		for id, p := range db {
+			cp := cloneProduct(p)
+			cp.ID = id
+			if cp.Version == 0 {
+				cp.Version = 1
+			}
+			defaultDb[id] = cp
+
+			if id > defaultLastID {
+				defaultLastID = id
+			}
+		}
+	}
+
+	return &ImplStorageProductMap{db: defaultDb, lastID: defaultLastID}
+}
+
+// ImplStorageProductMap is an in-memory implementation of StorageProduct interface
+// - it is safe for concurrent use
+// - text comparisons are case-insensitive, as with the default MySQL collation
+// - products are copied in and out so callers never share memory with the storage
+type ImplStorageProductMap struct {
+	// mu protects db and lastID
+	mu sync.RWMutex
+	// db is the map of products by id
+	db map[int]*Product
+	// lastID is the last auto-increment id assigned
+	lastID int
+}
+
+// GetOne returns one product by id
+func (impl *ImplStorageProductMap) GetOne(ctx context.Context, id int) (p *Product, err error) {
+	impl.mu.RLock()
+	defer impl.mu.RUnlock()
+
+	return impl.getOne(id)
+}
+
+// getOne returns one product by id (the caller holds the lock)
+func (impl *ImplStorageProductMap) getOne(id int) (p *Product, err error) {
+	product, ok := impl.db[id]
+	if !ok {
+		err = fmt.Errorf("%w. id %d", ErrStorageProductNotFound, id)
+		return
+	}
+
+	// serialization
+	p = cloneProduct(product)
+	return
+}
+
+// GetAll returns the products matching the query and the total count of matching products
+func (impl *ImplStorageProductMap) GetAll(ctx context.Context, q *QueryProduct) (ps []*Product, total int, err error) {
+	impl.mu.RLock()
+	defer impl.mu.RUnlock()
+
+	return impl.getAll(q)
+}
+
+// getAll returns the products matching the query and the total count of matching products (the caller holds the lock)
+func (impl *ImplStorageProductMap) getAll(q *QueryProduct) (ps []*Product, total int, err error) {
+	// check sort fields
+	for _, s := range q.Sort {
+		if _, err = ParseFieldProduct(string(s.Field)); err != nil {
+			return
+		}
+	}
+
+	// filter
+	matches := make([]*Product, 0)
+	for _, product := range impl.db {
+		if !matchProduct(product, &q.Filter) {
+			continue
+		}
+		matches = append(matches, product)
+	}
+	total = len(matches)
+
+	// sort
+	sort.Slice(matches, func(i, j int) bool {
+		return lessProduct(matches[i], matches[j], q.Sort)
+	})
+
+	// page
+	ps = make([]*Product, 0)
+	for i := q.Offset; i < len(matches) && i < q.Offset+q.Limit; i++ {
+		ps = append(ps, cloneProduct(matches[i]))
+	}
+
+	return
+}
+
+// Each calls fn with each product matching the filter, ordered by id
+// - fn is called without holding the lock, on a snapshot of the matching products
+// - the other operations only work in memory, so they do not check ctx
+func (impl *ImplStorageProductMap) Each(ctx context.Context, f *FilterProduct, fn func(p *Product) (err error)) (err error) {
+	impl.mu.RLock()
+	matches := impl.matches(f)
+	impl.mu.RUnlock()
+
+	return each(ctx, matches, fn)
+}
+
+// matches returns a copy of the products matching the filter, ordered by id (the caller holds the lock)
+func (impl *ImplStorageProductMap) matches(f *FilterProduct) (ps []*Product) {
+	// filter
+	ps = make([]*Product, 0)
+	for _, product := range impl.db {
+		if matchProduct(product, f) {
+			ps = append(ps, cloneProduct(product))
+		}
+	}
+
+	// sort
+	sort.Slice(ps, func(i, j int) bool {
+		return ps[i].ID < ps[j].ID
+	})
+	return
+}
+
+// each calls fn with each product until fn fails or ctx is done
+func each(ctx context.Context, ps []*Product, fn func(p *Product) (err error)) (err error) {
+	for _, p := range ps {
+		if ctx.Err() != nil {
+			err = fmt.Errorf("%w. %v", ErrStorageProductInternal, ctx.Err())
+			return
+		}
+		if err = fn(p); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Store stores product
+func (impl *ImplStorageProductMap) Store(ctx context.Context, p *Product) (err error) {
+	impl.mu.Lock()
+	defer impl.mu.Unlock()
+
+	return impl.store(p)
+}
+
+// store stores product (the caller holds the lock)
+func (impl *ImplStorageProductMap) store(p *Product) (err error) {
+	// check uniqueness
+	if impl.nameTaken((*p).Name, 0) {
+		err = fmt.Errorf("%w. name %q", ErrStorageProductNotUnique, (*p).Name)
+		return
+	}
+
+	// set id and version
+	impl.lastID++
+	(*p).ID = impl.lastID
+	(*p).Version = 1
+	(*p).UpdatedAt = now()
+
+	// store
+	impl.db[(*p).ID] = cloneProduct(p)
+	return
+}
+
+// Update updates product
+func (impl *ImplStorageProductMap) Update(ctx context.Context, p *Product) (err error) {
+	impl.mu.Lock()
+	defer impl.mu.Unlock()
+
+	return impl.update(p)
+}
+
+// update updates product (the caller holds the lock)
+func (impl *ImplStorageProductMap) update(p *Product) (err error) {
+	// check existence
+	current, ok := impl.db[(*p).ID]
+	if !ok {
+		err = fmt.Errorf("%w. id %d", ErrStorageProductNotFound, (*p).ID)
+		return
+	}
+
+	// check version
+	if (*p).Version != 0 && (*p).Version != current.Version {
+		err = fmt.Errorf("%w. id %d has version %d", ErrStorageProductVersionMismatch, (*p).ID, current.Version)
+		return
+	}
+
+	// check uniqueness
+	if impl.nameTaken((*p).Name, (*p).ID) {
+		err = fmt.Errorf("%w. name %q", ErrStorageProductNotUnique, (*p).Name)
+		return
+	}
+
+	// update
+	(*p).Version = current.Version + 1
+	(*p).UpdatedAt = now()
+	impl.db[(*p).ID] = cloneProduct(p)
+	return
+}
+
+// Delete deletes product by id
+func (impl *ImplStorageProductMap) Delete(ctx context.Context, id int, version int) (err error) {
+	impl.mu.Lock()
+	defer impl.mu.Unlock()
+
+	return impl.delete(id, version)
+}
+
+// delete deletes product by id (the caller holds the lock)
+func (impl *ImplStorageProductMap) delete(id int, version int) (err error) {
+	// check existence
+	current, ok := impl.db[id]
+	if !ok {
+		err = fmt.Errorf("%w. id %d", ErrStorageProductNotFound, id)
+		return
+	}
+
+	// check version
+	if version != 0 && version != current.Version {
+		err = fmt.Errorf("%w. id %d has version %d", ErrStorageProductVersionMismatch, id, current.Version)
+		return
+	}
+
+	// delete
+	delete(impl.db, id)
+	return
+}
+
+// StoreMany stores products like Store, all at once
+func (impl *ImplStorageProductMap) StoreMany(ctx context.Context, ps []*Product, mode BatchMode) (errs []error, err error) {
+	impl.mu.Lock()
+	defer impl.mu.Unlock()
+
+	return impl.storeMany(ps, mode)
+}
+
+// storeMany stores products like store, all at once (the caller holds the lock)
+func (impl *ImplStorageProductMap) storeMany(ps []*Product, mode BatchMode) (errs []error, err error) {
+	// work on copies, so the products are only modified if the batch is applied
+	work := make([]*Product, len(ps))
+	for i, p := range ps {
+		work[i] = cloneProduct(p)
+	}
+
+	errs, err = impl.batch(len(work), mode, func(i int) error { return impl.store(work[i]) })
+	if err != nil {
+		return
+	}
+
+	for i := range ps {
+		if errs[i] == nil {
+			*ps[i] = *work[i]
+		}
+	}
+	return
+}
+
+// UpdateMany updates products like Update, all at once
+func (impl *ImplStorageProductMap) UpdateMany(ctx context.Context, ps []*Product, mode BatchMode) (errs []error, err error) {
+	impl.mu.Lock()
+	defer impl.mu.Unlock()
+
+	return impl.updateMany(ps, mode)
+}
+
+// updateMany updates products like update, all at once (the caller holds the lock)
+func (impl *ImplStorageProductMap) updateMany(ps []*Product, mode BatchMode) (errs []error, err error) {
+	// work on copies, so the products are only modified if the batch is applied
+	work := make([]*Product, len(ps))
+	for i, p := range ps {
+		work[i] = cloneProduct(p)
+	}
+
+	errs, err = impl.batch(len(work), mode, func(i int) error { return impl.update(work[i]) })
+	if err != nil {
+		return
+	}
+
+	for i := range ps {
+		if errs[i] == nil {
+			*ps[i] = *work[i]
+		}
+	}
+	return
+}
+
+// DeleteMany deletes products by id like Delete, all at once
+func (impl *ImplStorageProductMap) DeleteMany(ctx context.Context, ids []int, mode BatchMode) (errs []error, err error) {
+	impl.mu.Lock()
+	defer impl.mu.Unlock()
+
+	return impl.deleteMany(ids, mode)
+}
+
+// deleteMany deletes products by id like delete, all at once (the caller holds the lock)
+func (impl *ImplStorageProductMap) deleteMany(ids []int, mode BatchMode) (errs []error, err error) {
+	return impl.batch(len(ids), mode, func(i int) error { return impl.delete(ids[i], 0) })
+}
+
+// batch applies the n operations of a batch (the caller holds the lock)
+// - BatchModeAtomic: the storage is restored if any operation fails
+func (impl *ImplStorageProductMap) batch(n int, mode BatchMode, apply func(i int) error) (errs []error, err error) {
+	restore := impl.snapshot()
+
+	// apply
+	errs = make([]error, n)
+	var failed bool
+	for i := 0; i < n; i++ {
+		errs[i] = apply(i)
+		failed = failed || errs[i] != nil
+
+		if failed && mode == BatchModeAtomic {
+			break
+		}
+	}
+
+	// restore
+	if failed && mode == BatchModeAtomic {
+		restore()
+		err = abortBatch(errs, nil)
+	}
+	return
+}
+
+// WithTx runs fn with a view of the storage that holds the lock until fn returns
+// - the storage is restored if fn fails
+func (impl *ImplStorageProductMap) WithTx(ctx context.Context, fn func(tx StorageProduct) (err error)) (err error) {
+	impl.mu.Lock()
+	defer impl.mu.Unlock()
+
+	return impl.withTx(fn)
+}
+
+// withTx runs fn with a view of the storage that does not lock (the caller holds the lock)
+func (impl *ImplStorageProductMap) withTx(fn func(tx StorageProduct) (err error)) (err error) {
+	restore := impl.snapshot()
+
+	err = fn(&txStorageProductMap{impl: impl})
+	if err != nil {
+		restore()
+	}
+	return
+}
+
+// snapshot returns a function that restores the storage to its current state (the caller holds the lock)
+func (impl *ImplStorageProductMap) snapshot() (restore func()) {
+	// products are never modified in place, so a shallow copy is enough
+	db := make(map[int]*Product, len(impl.db))
+	for id, p := range impl.db {
+		db[id] = p
+	}
+	lastID := impl.lastID
+
+	return func() {
+		impl.db, impl.lastID = db, lastID
+	}
+}
+
+// txStorageProductMap is the StorageProduct given to the fn of ImplStorageProductMap.WithTx
+// - it runs the operations without locking, as WithTx holds the lock
+type txStorageProductMap struct {
+	// impl is the storage of the transaction
+	impl *ImplStorageProductMap
+}
+
+// GetOne returns one product by id
+func (tx *txStorageProductMap) GetOne(ctx context.Context, id int) (p *Product, err error) {
+	return tx.impl.getOne(id)
+}
+
+// GetAll returns the products matching the query and the total count of matching products
+func (tx *txStorageProductMap) GetAll(ctx context.Context, q *QueryProduct) (ps []*Product, total int, err error) {
+	return tx.impl.getAll(q)
+}
+
+// Each calls fn with each product matching the filter, ordered by id
+func (tx *txStorageProductMap) Each(ctx context.Context, f *FilterProduct, fn func(p *Product) (err error)) (err error) {
+	return each(ctx, tx.impl.matches(f), fn)
+}
+
+// Store stores product
+func (tx *txStorageProductMap) Store(ctx context.Context, p *Product) (err error) {
+	return tx.impl.store(p)
+}
+
+// Update updates product
+func (tx *txStorageProductMap) Update(ctx context.Context, p *Product) (err error) {
+	return tx.impl.update(p)
+}
+
+// Delete deletes product by id
+func (tx *txStorageProductMap) Delete(ctx context.Context, id int, version int) (err error) {
+	return tx.impl.delete(id, version)
+}
+
+// StoreMany stores products like Store, all at once
+func (tx *txStorageProductMap) StoreMany(ctx context.Context, ps []*Product, mode BatchMode) (errs []error, err error) {
+	return tx.impl.storeMany(ps, mode)
+}
+
+// UpdateMany updates products like Update, all at once
+func (tx *txStorageProductMap) UpdateMany(ctx context.Context, ps []*Product, mode BatchMode) (errs []error, err error) {
+	return tx.impl.updateMany(ps, mode)
+}
+
+// DeleteMany deletes products by id like Delete, all at once
+func (tx *txStorageProductMap) DeleteMany(ctx context.Context, ids []int, mode BatchMode) (errs []error, err error) {
+	return tx.impl.deleteMany(ids, mode)
+}
+
+// WithTx runs fn within the transaction, the storage is restored to its state before fn if fn fails
+func (tx *txStorageProductMap) WithTx(ctx context.Context, fn func(tx StorageProduct) (err error)) (err error) {
+	return tx.impl.withTx(fn)
+}
+
+// nameTaken reports whether a product other than the one with exceptID has the name
+func (impl *ImplStorageProductMap) nameTaken(name string, exceptID int) bool {
+	for id, product := range impl.db {
+		if id != exceptID && strings.EqualFold(product.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchProduct reports whether the product matches the filter
+// - unknown (nil) fields never match a filter on that field, as NULL in SQL
+func matchProduct(p *Product, f *FilterProduct) bool {
+	switch {
+	case f.Type != nil && (p.Type == nil || !strings.EqualFold(*p.Type, *f.Type)):
+		return false
+	case f.MinPrice != nil && (p.Price == nil || *p.Price < *f.MinPrice):
+		return false
+	case f.MaxPrice != nil && (p.Price == nil || *p.Price > *f.MaxPrice):
+		return false
+	case f.MinCount != nil && (p.Count == nil || *p.Count < *f.MinCount):
+		return false
+	case f.MaxCount != nil && (p.Count == nil || *p.Count > *f.MaxCount):
+		return false
+	case f.Name != "" && !strings.Contains(strings.ToLower(p.Name), strings.ToLower(f.Name)):
+		return false
+	}
+	return true
+}
+
+// lessProduct reports whether a sorts before b (id is always the last tie-breaker)
+// - unknown (nil) fields sort first in ascending order, as NULL in MySQL
+func lessProduct(a, b *Product, sorts []SortProduct) bool {
+	for _, s := range sorts {
+		var cmp int
+		switch s.Field {
+		case FieldProductID:
+			cmp = compare(&a.ID, &b.ID)
+		case FieldProductName:
+			cmp = compare(lower(&a.Name), lower(&b.Name))
+		case FieldProductType:
+			cmp = compare(lower(a.Type), lower(b.Type))
+		case FieldProductCount:
+			cmp = compare(a.Count, b.Count)
+		case FieldProductPrice:
+			cmp = compare(a.Price, b.Price)
+		}
+		if cmp == 0 {
+			continue
+		}
+		if s.Desc {
+			return cmp > 0
+		}
+		return cmp < 0
+	}
+	return a.ID < b.ID
+}
+
+// compare returns -1, 0 or 1 comparing a and b (nil is less than any value)
+func compare[T int | float64 | string](a, b *T) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	case *a < *b:
+		return -1
+	case *a > *b:
+		return 1
+	}
+	return 0
+}
+
+// lower returns the lowercase copy of s (nil if s is nil)
+func lower(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	l := strings.ToLower(*s)
+	return &l
+}
+
+// cloneProduct returns a deep copy of p
+func cloneProduct(p *Product) *Product {
+	cp := *p
+	if p.Type != nil {
+		v := *p.Type
+		cp.Type = &v
+	}
+	if p.Count != nil {
+		v := *p.Count
+		cp.Count = &v
+	}
+	if p.Price != nil {
+		v := *p.Price
+		cp.Price = &v
+	}
+	return &cp
+}
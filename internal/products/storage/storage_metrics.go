@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"app/pkg/metrics"
+	"context"
+	"errors"
+	"time"
+)
+
+// NewImplStorageProductMetrics returns new ImplStorageProductMetrics
+// - storage_product_operation_duration_seconds is registered in registry
+func NewImplStorageProductMetrics(st StorageProduct, registry *metrics.Registry) *ImplStorageProductMetrics {
+	durations := registry.Histogram("storage_product_operation_duration_seconds", "Duration of the storage operations on products in seconds.", metrics.DefaultBuckets, "operation", "outcome")
+	return &ImplStorageProductMetrics{st: st, durations: durations}
+}
+
+// ImplStorageProductMetrics is a StorageProduct that observes the duration of the operations of another one
+// - GetOne, Store, Update and Delete are observed by outcome: ok, not_found, not_unique, version_mismatch or internal,
+//   also within transactions
+// - the other operations are delegated as is
+type ImplStorageProductMetrics struct {
+	// st is the observed storage
+	st StorageProduct
+	// durations is the histogram of the durations
+	durations *metrics.Histogram
+}
+
+// observe observes the duration of an operation started at start that returned err
+func (impl *ImplStorageProductMetrics) observe(operation string, start time.Time, err error) {
+	impl.durations.Observe(time.Since(start).Seconds(), operation, outcomeStorageProduct(err))
+}
+
+// outcomeStorageProduct returns the outcome label of an error of the storage
+func outcomeStorageProduct(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, ErrStorageProductNotFound):
+		return "not_found"
+	case errors.Is(err, ErrStorageProductNotUnique):
+		return "not_unique"
+	case errors.Is(err, ErrStorageProductVersionMismatch):
+		return "version_mismatch"
+	}
+	return "internal"
+}
+
+// GetOne returns one product by id
+func (impl *ImplStorageProductMetrics) GetOne(ctx context.Context, id int) (p *Product, err error) {
+	defer func(start time.Time) { impl.observe("get_one", start, err) }(time.Now())
+	return impl.st.GetOne(ctx, id)
+}
+
+// GetAll returns the products matching the query and the total count of matching products
+func (impl *ImplStorageProductMetrics) GetAll(ctx context.Context, q *QueryProduct) (ps []*Product, total int, err error) {
+	return impl.st.GetAll(ctx, q)
+}
+
+// Each calls fn with each product matching the filter, ordered by id
+func (impl *ImplStorageProductMetrics) Each(ctx context.Context, f *FilterProduct, fn func(p *Product) (err error)) (err error) {
+	return impl.st.Each(ctx, f, fn)
+}
+
+// Store stores product
+func (impl *ImplStorageProductMetrics) Store(ctx context.Context, p *Product) (err error) {
+	defer func(start time.Time) { impl.observe("store", start, err) }(time.Now())
+	return impl.st.Store(ctx, p)
+}
+
+// Update updates product
+func (impl *ImplStorageProductMetrics) Update(ctx context.Context, p *Product) (err error) {
+	defer func(start time.Time) { impl.observe("update", start, err) }(time.Now())
+	return impl.st.Update(ctx, p)
+}
+
+// Delete deletes product by id
+func (impl *ImplStorageProductMetrics) Delete(ctx context.Context, id int, version int) (err error) {
+	defer func(start time.Time) { impl.observe("delete", start, err) }(time.Now())
+	return impl.st.Delete(ctx, id, version)
+}
+
+// StoreMany stores products
+func (impl *ImplStorageProductMetrics) StoreMany(ctx context.Context, ps []*Product, mode BatchMode) (errs []error, err error) {
+	return impl.st.StoreMany(ctx, ps, mode)
+}
+
+// UpdateMany updates products
+func (impl *ImplStorageProductMetrics) UpdateMany(ctx context.Context, ps []*Product, mode BatchMode) (errs []error, err error) {
+	return impl.st.UpdateMany(ctx, ps, mode)
+}
+
+// DeleteMany deletes products by id
+func (impl *ImplStorageProductMetrics) DeleteMany(ctx context.Context, ids []int, mode BatchMode) (errs []error, err error) {
+	return impl.st.DeleteMany(ctx, ids, mode)
+}
+
+// WithTx runs fn in a transaction, the operations of fn are observed too
+func (impl *ImplStorageProductMetrics) WithTx(ctx context.Context, fn func(tx StorageProduct) (err error)) (err error) {
+	return impl.st.WithTx(ctx, func(tx StorageProduct) (err error) {
+		return fn(&ImplStorageProductMetrics{st: tx, durations: impl.durations})
+	})
+}
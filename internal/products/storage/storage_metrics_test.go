@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"app/pkg/metrics"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Tests for ImplStorageProductMetrics outcomes
+func TestImplStorageProductMetrics_Outcomes(t *testing.T) {
+	// arrange
+	registry := metrics.NewRegistry()
+	st := NewImplStorageProductMetrics(NewImplStorageProductMap(map[int]*Product{
+		1: {ID: 1, Name: "apple", Version: 1},
+	}), registry)
+	ctx := context.Background()
+
+	// act
+	_, _ = st.GetOne(ctx, 1)
+	_, _ = st.GetOne(ctx, 2)
+	_ = st.Store(ctx, &Product{Name: "apple"})
+	_ = st.Update(ctx, &Product{ID: 1, Name: "pear", Version: 3})
+	_ = st.WithTx(ctx, func(tx StorageProduct) error {
+		return tx.Delete(ctx, 1, 0)
+	})
+
+	// assert
+	var b strings.Builder
+	require.NoError(t, registry.Write(&b))
+	for _, series := range []string{
+		`{operation="get_one",outcome="ok"} 1`,
+		`{operation="get_one",outcome="not_found"} 1`,
+		`{operation="store",outcome="not_unique"} 1`,
+		`{operation="update",outcome="version_mismatch"} 1`,
+		`{operation="delete",outcome="ok"} 1`,
+	} {
+		require.Contains(t, b.String(), "storage_product_operation_duration_seconds_count"+series)
+	}
+}
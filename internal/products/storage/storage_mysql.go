@@ -1,16 +1,58 @@
 package storage
 
 import (
+	"app/pkg/trace"
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/go-sql-driver/mysql"
 )
 
+// ConfigStorageProductMySQL is the configuration of ImplStorageProductMySQL
+type ConfigStorageProductMySQL struct {
+	// QueryTimeout is the max duration of each operation (a batch is one operation),
+	// on top of the deadline of its context
+	QueryTimeout time.Duration
+	// Logger logs the failures the storage can not return, like failed rollbacks (slog.Default() by default)
+	// - it is called with the context of the operation
+	Logger *slog.Logger
+	// Tracer starts a span for each operation, child of the span of its context (nil disables tracing)
+	Tracer *trace.Tracer
+}
+
 // NewImplStorageProductMySQL returns new ImplStorageProductMySQL
-func NewImplStorageProductMySQL(db *sql.DB) *ImplStorageProductMySQL {
-	return &ImplStorageProductMySQL{db: db}
+// - cfg can be nil or have zero values, in that case defaults are used
+func NewImplStorageProductMySQL(db *sql.DB, cfg *ConfigStorageProductMySQL) *ImplStorageProductMySQL {
+	// default config
+	defaultCfg := ConfigStorageProductMySQL{
+		QueryTimeout: 10 * time.Second,
+		Logger: slog.Default(),
+	}
+	if cfg != nil {
+		if cfg.QueryTimeout > 0 {
+			defaultCfg.QueryTimeout = cfg.QueryTimeout
+		}
+		if cfg.Logger != nil {
+			defaultCfg.Logger = cfg.Logger
+		}
+		defaultCfg.Tracer = cfg.Tracer
+	}
+
+	return &ImplStorageProductMySQL{db: db, ex: db, cfg: defaultCfg}
+}
+
+// executorMySQL executes queries, it is implemented by *sql.DB and *sql.Tx
+type executorMySQL interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
 }
 
 // ProductMySQL is a product model for MySQL
@@ -20,21 +62,63 @@ type ProductMySQL struct {
 	Type	sql.NullString
 	Count	sql.NullInt32
 	Price	sql.NullFloat64
+	Version	sql.NullInt32
+	UpdatedAt	sql.NullTime
 }
 
 // ImplStorageProductMySQL is an implementation of StorageProduct interface
 type ImplStorageProductMySQL struct {
+	// db is the database, used to begin transactions
 	db *sql.DB
+	// ex executes the queries: the database, or the transaction the storage is bound to
+	ex executorMySQL
+	// cfg is the configuration
+	cfg ConfigStorageProductMySQL
+	// depth is the transaction nesting level of ex (0 if ex is the database)
+	depth int
+}
+
+// context returns ctx bounded by the query timeout
+func (impl *ImplStorageProductMySQL) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, impl.cfg.QueryTimeout)
+}
+
+// trace starts the span of an operation, end ends it with the error the operation returned
+func (impl *ImplStorageProductMySQL) trace(ctx context.Context, operation string) (ctxSpan context.Context, end func(err error)) {
+	ctxSpan, span := impl.cfg.Tracer.Start(ctx, "StorageProductMySQL."+operation)
+	span.SetAttr("db.system", "mysql")
+	span.SetAttr("db.operation", operation)
+	if impl.depth > 0 {
+		span.SetAttr("db.tx_depth", impl.depth)
+	}
+
+	end = func(err error) {
+		span.RecordError(err)
+		span.End()
+	}
+	return
 }
 
 // GetOne returns one product by id
-func (impl *ImplStorageProductMySQL) GetOne(id int) (p *Product, err error) {
+func (impl *ImplStorageProductMySQL) GetOne(ctx context.Context, id int) (p *Product, err error) {
+	// span
+	ctx, end := impl.trace(ctx, "GetOne")
+	defer func() { end(err) }()
+
+	// timeout
+	ctx, cancel := impl.context(ctx)
+	defer cancel()
+
 	// query
-	query := "SELECT id, name, type, count, price FROM products WHERE id = ?"
+	query := "SELECT id, name, type, count, price, version, updated_at FROM products WHERE id = ?"
+	// -> in a transaction the product is locked until it ends
+	if impl.depth > 0 {
+		query += " FOR UPDATE"
+	}
 
 	// prepare statement
 	var stmt *sql.Stmt
-	stmt, err = impl.db.Prepare(query)
+	stmt, err = impl.ex.PrepareContext(ctx, query)
 	if err != nil {
 		err = fmt.Errorf("%w. %v", ErrStorageProductInternal, err)
 		return
@@ -42,72 +126,275 @@ func (impl *ImplStorageProductMySQL) GetOne(id int) (p *Product, err error) {
 	defer stmt.Close()
 
 	// execute query
-	row := stmt.QueryRow(id)
-	if row.Err() != nil {
-		err = row.Err()
+	row := stmt.QueryRowContext(ctx, id)
+
+	// scan row
+	var product ProductMySQL
+	err = row.Scan(&product.ID, &product.Name, &product.Type, &product.Count, &product.Price, &product.Version, &product.UpdatedAt)
+	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
-			err = fmt.Errorf("%w. %v", ErrStorageProductNotFound, row.Err())
+			err = fmt.Errorf("%w. %v", ErrStorageProductNotFound, err)
 		default:
-			err = fmt.Errorf("%w. %v", ErrStorageProductInternal, row.Err())
+			err = fmt.Errorf("%w. %v", ErrStorageProductInternal, err)
 		}
 
 		return
 	}
 
-	// scan row
-	var product ProductMySQL
-	err = row.Scan(&product.ID, &product.Name, &product.Type, &product.Count, &product.Price)
-	if err != nil {
-		err = fmt.Errorf("%w. %v", ErrStorageProductInternal, err)
-		return
-	}
-
 	// serialization
+	p = serializeProductMySQL(&product)
+	return
+}
+
+// serializeProductMySQL returns the product of a scanned row
+func serializeProductMySQL(product *ProductMySQL) (p *Product) {
 	p = new(Product)
+	if product.ID.Valid {
+		(*p).ID = int(product.ID.Int32)
+	}
 	if product.Name.Valid {
 		(*p).Name = product.Name.String
 	}
 	if product.Type.Valid {
-		(*p).Type = product.Type.String
+		(*p).Type = &product.Type.String
 	}
 	if product.Count.Valid {
-		(*p).Count = int(product.Count.Int32)
+		count := int(product.Count.Int32)
+		(*p).Count = &count
 	}
 	if product.Price.Valid {
-		(*p).Price = product.Price.Float64
+		(*p).Price = &product.Price.Float64
+	}
+	if product.Version.Valid {
+		(*p).Version = int(product.Version.Int32)
+	}
+	if product.UpdatedAt.Valid {
+		(*p).UpdatedAt = product.UpdatedAt.Time.UTC()
+	}
+	return
+}
+
+// columnsProductMySQL maps the allowlisted product fields to their MySQL columns
+var columnsProductMySQL = map[FieldProduct]string{
+	FieldProductID:		"id",
+	FieldProductName:	"name",
+	FieldProductType:	"type",
+	FieldProductCount:	"count",
+	FieldProductPrice:	"price",
+}
+
+// escapeLikeMySQL escapes the wildcards of a LIKE pattern
+var escapeLikeMySQL = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// queryProductMySQL builds the where and order by clauses (with its arguments) of a QueryProduct
+// - identifiers only come from columnsProductMySQL, user input is always passed as an argument
+func queryProductMySQL(q *QueryProduct) (where string, args []any, orderBy string, err error) {
+	// where
+	var conditions []string
+	if q.Filter.Type != nil {
+		conditions = append(conditions, "type = ?")
+		args = append(args, *q.Filter.Type)
+	}
+	if q.Filter.MinPrice != nil {
+		conditions = append(conditions, "price >= ?")
+		args = append(args, *q.Filter.MinPrice)
+	}
+	if q.Filter.MaxPrice != nil {
+		conditions = append(conditions, "price <= ?")
+		args = append(args, *q.Filter.MaxPrice)
+	}
+	if q.Filter.MinCount != nil {
+		conditions = append(conditions, "count >= ?")
+		args = append(args, *q.Filter.MinCount)
+	}
+	if q.Filter.MaxCount != nil {
+		conditions = append(conditions, "count <= ?")
+		args = append(args, *q.Filter.MaxCount)
+	}
+	if q.Filter.Name != "" {
+		conditions = append(conditions, "name LIKE ?")
+		args = append(args, "%"+escapeLikeMySQL.Replace(q.Filter.Name)+"%")
+	}
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	// order by
+	var orders []string
+	var sortedByID bool
+	for _, s := range q.Sort {
+		column, ok := columnsProductMySQL[s.Field]
+		if !ok {
+			err = fmt.Errorf("%w. unknown field %q", ErrStorageProductQueryInvalid, s.Field)
+			return
+		}
+		direction := "ASC"
+		if s.Desc {
+			direction = "DESC"
+		}
+		orders = append(orders, column+" "+direction)
+		sortedByID = sortedByID || s.Field == FieldProductID
+	}
+	if !sortedByID {
+		orders = append(orders, "id ASC")
+	}
+	orderBy = " ORDER BY " + strings.Join(orders, ", ")
+
+	return
+}
+
+// GetAll returns the products matching the query and the total count of matching products
+func (impl *ImplStorageProductMySQL) GetAll(ctx context.Context, q *QueryProduct) (ps []*Product, total int, err error) {
+	// span
+	ctx, end := impl.trace(ctx, "GetAll")
+	defer func() { end(err) }()
+
+	// timeout
+	ctx, cancel := impl.context(ctx)
+	defer cancel()
+
+	// build clauses
+	where, args, orderBy, err := queryProductMySQL(q)
+	if err != nil {
+		return
+	}
+
+	// count
+	// -> query
+	queryCount := "SELECT COUNT(*) FROM products" + where
+
+	// -> execute query
+	err = impl.ex.QueryRowContext(ctx, queryCount, args...).Scan(&total)
+	if err != nil {
+		err = fmt.Errorf("%w. %v", ErrStorageProductInternal, err)
+		return
+	}
+
+	// page
+	// -> query
+	query := "SELECT id, name, type, count, price, version, updated_at FROM products" + where + orderBy + " LIMIT ? OFFSET ?"
+
+	// -> prepare statement
+	var stmt *sql.Stmt
+	stmt, err = impl.ex.PrepareContext(ctx, query)
+	if err != nil {
+		err = fmt.Errorf("%w. %v", ErrStorageProductInternal, err)
+		return
+	}
+	defer stmt.Close()
+
+	// -> execute query
+	var rows *sql.Rows
+	rows, err = stmt.QueryContext(ctx, append(args, q.Limit, q.Offset)...)
+	if err != nil {
+		err = fmt.Errorf("%w. %v", ErrStorageProductInternal, err)
+		return
+	}
+	defer rows.Close()
+
+	// -> scan rows
+	ps = make([]*Product, 0)
+	for rows.Next() {
+		var product ProductMySQL
+		err = rows.Scan(&product.ID, &product.Name, &product.Type, &product.Count, &product.Price, &product.Version, &product.UpdatedAt)
+		if err != nil {
+			err = fmt.Errorf("%w. %v", ErrStorageProductInternal, err)
+			return
+		}
+
+		// serialization
+		ps = append(ps, serializeProductMySQL(&product))
+	}
+	if rows.Err() != nil {
+		err = fmt.Errorf("%w. %v", ErrStorageProductInternal, rows.Err())
+		return
+	}
+
+	return
+}
+
+// Each calls fn with each product matching the filter, ordered by id
+// - the rows are streamed from the cursor, one at a time
+// - it is bounded by ctx only, not by the query timeout, as it lasts as long as fn takes
+func (impl *ImplStorageProductMySQL) Each(ctx context.Context, f *FilterProduct, fn func(p *Product) (err error)) (err error) {
+	// span
+	ctx, end := impl.trace(ctx, "Each")
+	defer func() { end(err) }()
+
+	// build clauses
+	where, args, orderBy, err := queryProductMySQL(&QueryProduct{Filter: *f})
+	if err != nil {
+		return
+	}
+
+	// query
+	query := "SELECT id, name, type, count, price, version, updated_at FROM products" + where + orderBy
+
+	// execute query
+	var rows *sql.Rows
+	rows, err = impl.ex.QueryContext(ctx, query, args...)
+	if err != nil {
+		err = fmt.Errorf("%w. %v", ErrStorageProductInternal, err)
+		return
+	}
+	defer rows.Close()
+
+	// scan rows
+	for rows.Next() {
+		var product ProductMySQL
+		err = rows.Scan(&product.ID, &product.Name, &product.Type, &product.Count, &product.Price, &product.Version, &product.UpdatedAt)
+		if err != nil {
+			err = fmt.Errorf("%w. %v", ErrStorageProductInternal, err)
+			return
+		}
+
+		// serialization
+		if err = fn(serializeProductMySQL(&product)); err != nil {
+			return
+		}
+	}
+	if rows.Err() != nil {
+		err = fmt.Errorf("%w. %v", ErrStorageProductInternal, rows.Err())
+		return
 	}
 
 	return
 }
 
 // Store stores product
-func (impl *ImplStorageProductMySQL) Store(p *Product) (err error) {
+func (impl *ImplStorageProductMySQL) Store(ctx context.Context, p *Product) (err error) {
+	// span
+	ctx, end := impl.trace(ctx, "Store")
+	defer func() { end(err) }()
+
+	// timeout
+	ctx, cancel := impl.context(ctx)
+	defer cancel()
+
 	// deserialize
 	var product ProductMySQL
-	if (*p).Name != "" {
-		product.Name.Valid = true
-		product.Name.String = (*p).Name
-	}
-	if (*p).Type != "" {
+	product.Name.Valid = true
+	product.Name.String = (*p).Name
+	if (*p).Type != nil {
 		product.Type.Valid = true
-		product.Type.String = (*p).Type
+		product.Type.String = *(*p).Type
 	}
-	if (*p).Count != 0 {
+	if (*p).Count != nil {
 		product.Count.Valid = true
-		product.Count.Int32 = int32((*p).Count)
+		product.Count.Int32 = int32(*(*p).Count)
 	}
-	if (*p).Price != 0 {
+	if (*p).Price != nil {
 		product.Price.Valid = true
-		product.Price.Float64 = (*p).Price
+		product.Price.Float64 = *(*p).Price
 	}
 
 	// query
-	query := "INSERT INTO products (name, type, count, price) VALUES (?, ?, ?, ?)"
+	query := "INSERT INTO products (name, type, count, price, version, updated_at) VALUES (?, ?, ?, ?, 1, ?)"
 
 	// prepare statement
 	var stmt *sql.Stmt
-	stmt, err = impl.db.Prepare(query)
+	stmt, err = impl.ex.PrepareContext(ctx, query)
 	if err != nil {
 		err = fmt.Errorf("%w. %v", ErrStorageProductInternal, err)
 		return
@@ -115,7 +402,8 @@ func (impl *ImplStorageProductMySQL) Store(p *Product) (err error) {
 	defer stmt.Close()
 
 	// execute query
-	result, err := stmt.Exec(product.Name, product.Type, product.Count, product.Price)
+	updatedAt := now()
+	result, err := stmt.ExecContext(ctx, product.Name, product.Type, product.Count, product.Price, updatedAt)
 	if err != nil {
 		errMySQL, ok := err.(*mysql.MySQLError); if ok {
 			switch errMySQL.Number {
@@ -152,37 +440,52 @@ func (impl *ImplStorageProductMySQL) Store(p *Product) (err error) {
 	}
 
 	(*p).ID = int(lastInsertID)
-	
+	(*p).Version = 1
+	(*p).UpdatedAt = updatedAt
+
 	return
 }
 
 // Update updates product
-func (impl *ImplStorageProductMySQL) Update(p *Product) (err error) {
+func (impl *ImplStorageProductMySQL) Update(ctx context.Context, p *Product) (err error) {
+	// span
+	ctx, end := impl.trace(ctx, "Update")
+	defer func() { end(err) }()
+
+	// timeout
+	ctx, cancel := impl.context(ctx)
+	defer cancel()
+
 	// deserialize
 	var product ProductMySQL
-	if (*p).Name != "" {
-		product.Name.Valid = true
-		product.Name.String = (*p).Name
-	}
-	if (*p).Type != "" {
+	product.Name.Valid = true
+	product.Name.String = (*p).Name
+	if (*p).Type != nil {
 		product.Type.Valid = true
-		product.Type.String = (*p).Type
+		product.Type.String = *(*p).Type
 	}
-	if (*p).Count != 0 {
+	if (*p).Count != nil {
 		product.Count.Valid = true
-		product.Count.Int32 = int32((*p).Count)
+		product.Count.Int32 = int32(*(*p).Count)
 	}
-	if (*p).Price != 0 {
+	if (*p).Price != nil {
 		product.Price.Valid = true
-		product.Price.Float64 = (*p).Price
+		product.Price.Float64 = *(*p).Price
 	}
 
 	// query
-	query := "UPDATE products SET name = ?, type = ?, count = ?, price = ? WHERE id = ?"
+	// -> the new version is returned as last insert id so it is read atomically
+	updatedAt := now()
+	query := "UPDATE products SET name = ?, type = ?, count = ?, price = ?, version = LAST_INSERT_ID(version + 1), updated_at = ? WHERE id = ?"
+	args := []any{product.Name, product.Type, product.Count, product.Price, updatedAt, (*p).ID}
+	if (*p).Version != 0 {
+		query += " AND version = ?"
+		args = append(args, (*p).Version)
+	}
 
 	// prepare statement
 	var stmt *sql.Stmt
-	stmt, err = impl.db.Prepare(query)
+	stmt, err = impl.ex.PrepareContext(ctx, query)
 	if err != nil {
 		err = fmt.Errorf("%w. %v", ErrStorageProductInternal, err)
 		return
@@ -190,8 +493,19 @@ func (impl *ImplStorageProductMySQL) Update(p *Product) (err error) {
 	defer stmt.Close()
 
 	// execute query
-	result, err := stmt.Exec(product.Name, product.Type, product.Count, product.Price, (*p).ID)
+	result, err := stmt.ExecContext(ctx, args...)
 	if err != nil {
+		errMySQL, ok := err.(*mysql.MySQLError); if ok {
+			switch errMySQL.Number {
+			case 1062:
+				err = fmt.Errorf("%w. %v", ErrStorageProductNotUnique, err)
+			default:
+				err = fmt.Errorf("%w. %v", ErrStorageProductInternal, err)
+			}
+
+			return
+		}
+
 		err = fmt.Errorf("%w. %v", ErrStorageProductInternal, err)
 		return
 	}
@@ -203,22 +517,51 @@ func (impl *ImplStorageProductMySQL) Update(p *Product) (err error) {
 		return
 	}
 
-	if rowsAffected != 1 {
-		err = fmt.Errorf("%w. %s", ErrStorageProductInternal, "rows affected != 1")
+	switch rowsAffected {
+	case 1:
+	case 0:
+		// the version always changes, so no rows affected means the product is missing or has another version
+		err = impl.errNoRows(ctx, (*p).ID, (*p).Version)
+		return
+	default:
+		err = fmt.Errorf("%w. %s", ErrStorageProductInternal, "rows affected > 1")
+		return
+	}
+
+	// get new version
+	version, err := result.LastInsertId()
+	if err != nil {
+		err = fmt.Errorf("%w. %v", ErrStorageProductInternal, err)
 		return
 	}
 
+	(*p).Version = int(version)
+	(*p).UpdatedAt = updatedAt
+
 	return
 }
 
 // Delete deletes product by id
-func (impl *ImplStorageProductMySQL) Delete(id int) (err error) {
+func (impl *ImplStorageProductMySQL) Delete(ctx context.Context, id int, version int) (err error) {
+	// span
+	ctx, end := impl.trace(ctx, "Delete")
+	defer func() { end(err) }()
+
+	// timeout
+	ctx, cancel := impl.context(ctx)
+	defer cancel()
+
 	// query
 	query := "DELETE FROM products WHERE id = ?"
+	args := []any{id}
+	if version != 0 {
+		query += " AND version = ?"
+		args = append(args, version)
+	}
 
 	// prepare statement
 	var stmt *sql.Stmt
-	stmt, err = impl.db.Prepare(query)
+	stmt, err = impl.ex.PrepareContext(ctx, query)
 	if err != nil {
 		err = fmt.Errorf("%w. %v", ErrStorageProductInternal, err)
 		return
@@ -226,7 +569,7 @@ func (impl *ImplStorageProductMySQL) Delete(id int) (err error) {
 	defer stmt.Close()
 
 	// execute query
-	result, err := stmt.Exec(id)
+	result, err := stmt.ExecContext(ctx, args...)
 	if err != nil {
 		err = fmt.Errorf("%w. %v", ErrStorageProductInternal, err)
 		return
@@ -239,10 +582,477 @@ func (impl *ImplStorageProductMySQL) Delete(id int) (err error) {
 		return
 	}
 
-	if rowsAffected != 1 {
-		err = fmt.Errorf("%w. %s", ErrStorageProductInternal, "rows affected != 1")
+	switch rowsAffected {
+	case 1:
+	case 0:
+		err = impl.errNoRows(ctx, id, version)
+		return
+	default:
+		err = fmt.Errorf("%w. %s", ErrStorageProductInternal, "rows affected > 1")
 		return
 	}
 
 	return
-}
\ No newline at end of file
+}
+
+// errNoRows returns the error of a statement on the product id (with the expected version) that affected no rows
+// - ErrStorageProductVersionMismatch if the product exists, ErrStorageProductNotFound otherwise
+func (impl *ImplStorageProductMySQL) errNoRows(ctx context.Context, id int, version int) (err error) {
+	if version == 0 {
+		err = fmt.Errorf("%w. id %d", ErrStorageProductNotFound, id)
+		return
+	}
+
+	var exists bool
+	err = impl.ex.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM products WHERE id = ?)", id).Scan(&exists)
+	if err != nil {
+		err = fmt.Errorf("%w. %v", ErrStorageProductInternal, err)
+		return
+	}
+	if !exists {
+		err = fmt.Errorf("%w. id %d", ErrStorageProductNotFound, id)
+		return
+	}
+
+	err = fmt.Errorf("%w. id %d does not have version %d", ErrStorageProductVersionMismatch, id, version)
+	return
+}
+
+// WithTx runs fn with a copy of the storage bound to a transaction
+// - the whole transaction is bounded by the query timeout, as it holds locks until it ends
+func (impl *ImplStorageProductMySQL) WithTx(ctx context.Context, fn func(tx StorageProduct) (err error)) (err error) {
+	// span
+	ctx, end := impl.trace(ctx, "WithTx")
+	defer func() { end(err) }()
+
+	// timeout
+	ctx, cancel := impl.context(ctx)
+	defer cancel()
+
+	return impl.tx(ctx, func(tx *ImplStorageProductMySQL) (err error) {
+		return fn(tx)
+	})
+}
+
+// tx runs fn with a copy of the storage bound to a transaction
+// - the transaction is committed if fn returns nil, rolled back otherwise
+// - if the storage is already bound to a transaction fn runs within a savepoint of it
+func (impl *ImplStorageProductMySQL) tx(ctx context.Context, fn func(tx *ImplStorageProductMySQL) (err error)) (err error) {
+	// nested
+	if impl.depth > 0 {
+		return impl.savepoint(ctx, fn)
+	}
+
+	// begin
+	var tx *sql.Tx
+	tx, err = impl.db.BeginTx(ctx, nil)
+	if err != nil {
+		err = fmt.Errorf("%w. %v", ErrStorageProductInternal, err)
+		return
+	}
+
+	// run
+	err = fn(&ImplStorageProductMySQL{db: impl.db, ex: tx, cfg: impl.cfg, depth: 1})
+	if err != nil {
+		// (a transaction whose context is done is already rolled back)
+		if errRollback := tx.Rollback(); errRollback != nil && !errors.Is(errRollback, sql.ErrTxDone) {
+			impl.cfg.Logger.ErrorContext(ctx, "storage product rollback failed", slog.String("error", errRollback.Error()), slog.String("cause", err.Error()))
+		}
+		return
+	}
+
+	// commit
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("%w. %v", ErrStorageProductInternal, err)
+		return
+	}
+
+	return
+}
+
+// savepoint runs fn with a copy of the storage bound to a savepoint of its transaction
+// - the savepoint is released if fn returns nil, rolled back to otherwise
+func (impl *ImplStorageProductMySQL) savepoint(ctx context.Context, fn func(tx *ImplStorageProductMySQL) (err error)) (err error) {
+	name := fmt.Sprintf("sp%d", impl.depth)
+
+	// begin
+	_, err = impl.ex.ExecContext(ctx, "SAVEPOINT "+name)
+	if err != nil {
+		err = fmt.Errorf("%w. %v", ErrStorageProductInternal, err)
+		return
+	}
+
+	// run
+	err = fn(&ImplStorageProductMySQL{db: impl.db, ex: impl.ex, cfg: impl.cfg, depth: impl.depth + 1})
+	if err != nil {
+		if _, errRollback := impl.ex.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); errRollback != nil {
+			impl.cfg.Logger.ErrorContext(ctx, "storage product rollback to savepoint failed", slog.String("savepoint", name), slog.String("error", errRollback.Error()), slog.String("cause", err.Error()))
+		}
+		return
+	}
+
+	// release
+	_, err = impl.ex.ExecContext(ctx, "RELEASE SAVEPOINT "+name)
+	if err != nil {
+		err = fmt.Errorf("%w. %v", ErrStorageProductInternal, err)
+		return
+	}
+
+	return
+}
+
+// batchSizeMySQL is the max amount of rows of a multi-row statement
+const batchSizeMySQL = 500
+
+// StoreMany stores products like Store, in one transaction, with multi-row inserts of batchSizeMySQL rows
+// - BatchModeBestEffort inserts row by row the chunks whose multi-row insert failed, so only the failing
+//   products are not stored (a failed insert only rolls back itself, not the transaction)
+func (impl *ImplStorageProductMySQL) StoreMany(ctx context.Context, ps []*Product, mode BatchMode) (errs []error, err error) {
+	// span
+	ctx, end := impl.trace(ctx, "StoreMany")
+	defer func() { end(err) }()
+
+	// timeout
+	ctx, cancel := impl.context(ctx)
+	defer cancel()
+
+	errs = make([]error, len(ps))
+
+	switch mode {
+	case BatchModeBestEffort:
+		// work on copies, so the products are only modified if the transaction is committed
+		work := make([]*Product, len(ps))
+		for i, p := range ps {
+			cp := *p
+			work[i] = &cp
+		}
+
+		ids := make([]int, len(ps))
+		updatedAt := now()
+		err = impl.tx(ctx, func(tx *ImplStorageProductMySQL) (err error) {
+			for start := 0; start < len(work); start += batchSizeMySQL {
+				end := start + batchSizeMySQL
+				if end > len(work) {
+					end = len(work)
+				}
+
+				// -> multi-row insert of the chunk, in a savepoint so a failure leaves nothing of it
+				errChunk := tx.tx(ctx, func(sp *ImplStorageProductMySQL) (err error) {
+					return sp.insertMany(ctx, work[start:end], ids[start:end], make([]error, end-start), updatedAt)
+				})
+				if errChunk == nil {
+					for i := start; i < end; i++ {
+						(*work[i]).ID = ids[i]
+						(*work[i]).Version = 1
+						(*work[i]).UpdatedAt = updatedAt
+					}
+					continue
+				}
+
+				// -> a product failed, the chunk is inserted row by row to tell which one
+				//    (the failed statement only rolled back itself, not the transaction)
+				for i := start; i < end; i++ {
+					errs[i] = tx.Store(ctx, work[i])
+				}
+			}
+			return
+		})
+		if err != nil {
+			// the transaction failed, so nothing was stored
+			for i := range errs {
+				errs[i] = err
+			}
+			return
+		}
+
+		for i := range ps {
+			if errs[i] == nil {
+				*ps[i] = *work[i]
+			}
+		}
+		return
+	default:
+		ids := make([]int, len(ps))
+		updatedAt := now()
+		err = impl.tx(ctx, func(tx *ImplStorageProductMySQL) (err error) {
+			for start := 0; start < len(ps); start += batchSizeMySQL {
+				end := start + batchSizeMySQL
+				if end > len(ps) {
+					end = len(ps)
+				}
+
+				err = tx.insertMany(ctx, ps[start:end], ids[start:end], errs[start:end], updatedAt)
+				if err != nil {
+					return
+				}
+			}
+			return
+		})
+		if err != nil {
+			err = abortBatch(errs, err)
+			return
+		}
+
+		// set ids, versions and updated at once committed
+		for i, p := range ps {
+			(*p).ID = ids[i]
+			(*p).Version = 1
+			(*p).UpdatedAt = updatedAt
+		}
+		return
+	}
+}
+
+// rxDuplicateEntryMySQL matches the duplicated value of a mysql 1062 error message
+var rxDuplicateEntryMySQL = regexp.MustCompile(`Duplicate entry '(.*)' for key`)
+
+// insertMany inserts products with one multi-row insert and sets the ids assigned to them
+// - the ids are read back by name (names are unique): they are not consecutive with innodb_autoinc_lock_mode 2
+//   (the default since MySQL 8) or auto_increment_increment > 1, so they can not be told from the last insert id
+// - on failure errs has the error of the failing products (when they can be told) and err is the failure
+func (impl *ImplStorageProductMySQL) insertMany(ctx context.Context, ps []*Product, ids []int, errs []error, updatedAt time.Time) (err error) {
+	// query
+	placeholders := make([]string, len(ps))
+	args := make([]any, 0, len(ps)*5)
+	for i, p := range ps {
+		// deserialize
+		var product ProductMySQL
+		product.Name.Valid = true
+		product.Name.String = (*p).Name
+		if (*p).Type != nil {
+			product.Type.Valid = true
+			product.Type.String = *(*p).Type
+		}
+		if (*p).Count != nil {
+			product.Count.Valid = true
+			product.Count.Int32 = int32(*(*p).Count)
+		}
+		if (*p).Price != nil {
+			product.Price.Valid = true
+			product.Price.Float64 = *(*p).Price
+		}
+
+		placeholders[i] = "(?, ?, ?, ?, 1, ?)"
+		args = append(args, product.Name, product.Type, product.Count, product.Price, updatedAt)
+	}
+	query := "INSERT INTO products (name, type, count, price, version, updated_at) VALUES " + strings.Join(placeholders, ", ")
+
+	// execute query
+	result, err := impl.ex.ExecContext(ctx, query, args...)
+	if err != nil {
+		errMySQL, ok := err.(*mysql.MySQLError); if ok && errMySQL.Number == 1062 {
+			err = fmt.Errorf("%w. %v", ErrStorageProductNotUnique, err)
+
+			// mark the products with the duplicated name
+			if m := rxDuplicateEntryMySQL.FindStringSubmatch(errMySQL.Message); m != nil {
+				for i, p := range ps {
+					if strings.EqualFold((*p).Name, m[1]) {
+						errs[i] = err
+					}
+				}
+			}
+			return
+		}
+
+		err = fmt.Errorf("%w. %v", ErrStorageProductInternal, err)
+		for i := range errs {
+			errs[i] = err
+		}
+		return
+	}
+
+	// check rows affected
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		err = fmt.Errorf("%w. %v", ErrStorageProductInternal, err)
+		return
+	}
+
+	if rowsAffected != int64(len(ps)) {
+		err = fmt.Errorf("%w. rows affected %d != %d", ErrStorageProductInternal, rowsAffected, len(ps))
+		return
+	}
+
+	// get ids (the rows inserted are visible to the transaction)
+	names := make([]any, len(ps))
+	for i, p := range ps {
+		names[i] = (*p).Name
+	}
+	query = "SELECT id, name FROM products WHERE name IN (?" + strings.Repeat(", ?", len(ps)-1) + ")"
+	rows, err := impl.ex.QueryContext(ctx, query, names...)
+	if err != nil {
+		err = fmt.Errorf("%w. %v", ErrStorageProductInternal, err)
+		return
+	}
+	defer rows.Close()
+
+	byName := make(map[string]int, len(ps))
+	for rows.Next() {
+		var id int
+		var name string
+		err = rows.Scan(&id, &name)
+		if err != nil {
+			err = fmt.Errorf("%w. %v", ErrStorageProductInternal, err)
+			return
+		}
+		byName[name] = id
+	}
+	if rows.Err() != nil {
+		err = fmt.Errorf("%w. %v", ErrStorageProductInternal, rows.Err())
+		return
+	}
+
+	for i, p := range ps {
+		id, ok := byName[(*p).Name]
+		if !ok {
+			err = fmt.Errorf("%w. id of %q not found after insert", ErrStorageProductInternal, (*p).Name)
+			return
+		}
+		ids[i] = id
+	}
+
+	return
+}
+
+// UpdateMany updates products like Update, in one transaction
+func (impl *ImplStorageProductMySQL) UpdateMany(ctx context.Context, ps []*Product, mode BatchMode) (errs []error, err error) {
+	// span
+	ctx, end := impl.trace(ctx, "UpdateMany")
+	defer func() { end(err) }()
+
+	// timeout
+	ctx, cancel := impl.context(ctx)
+	defer cancel()
+
+	errs = make([]error, len(ps))
+
+	// work on copies, so the products are only modified if the transaction is committed
+	work := make([]*Product, len(ps))
+	for i, p := range ps {
+		cp := *p
+		work[i] = &cp
+	}
+
+	err = impl.tx(ctx, func(tx *ImplStorageProductMySQL) (err error) {
+		for i, p := range work {
+			errs[i] = tx.Update(ctx, p)
+			if errs[i] != nil && mode == BatchModeAtomic {
+				err = errs[i]
+				return
+			}
+		}
+		return
+	})
+	if err != nil {
+		switch mode {
+		case BatchModeBestEffort:
+			// the transaction failed, so nothing was updated
+			for i := range errs {
+				errs[i] = err
+			}
+		default:
+			err = abortBatch(errs, err)
+		}
+		return
+	}
+
+	for i := range ps {
+		if errs[i] == nil {
+			(*ps[i]).Version = (*work[i]).Version
+			(*ps[i]).UpdatedAt = (*work[i]).UpdatedAt
+		}
+	}
+	return
+}
+
+// DeleteMany deletes products by id like Delete, in one transaction
+// - BatchModeAtomic locks the products and deletes them with one statement
+func (impl *ImplStorageProductMySQL) DeleteMany(ctx context.Context, ids []int, mode BatchMode) (errs []error, err error) {
+	// span
+	ctx, end := impl.trace(ctx, "DeleteMany")
+	defer func() { end(err) }()
+
+	// timeout
+	ctx, cancel := impl.context(ctx)
+	defer cancel()
+
+	errs = make([]error, len(ids))
+
+	switch mode {
+	case BatchModeBestEffort:
+		err = impl.tx(ctx, func(tx *ImplStorageProductMySQL) (err error) {
+			for i, id := range ids {
+				errs[i] = tx.Delete(ctx, id, 0)
+			}
+			return
+		})
+		if err != nil {
+			for i := range errs {
+				errs[i] = err
+			}
+		}
+		return
+	default:
+		if len(ids) == 0 {
+			return
+		}
+
+		placeholders := make([]string, len(ids))
+		args := make([]any, len(ids))
+		for i, id := range ids {
+			placeholders[i] = "?"
+			args[i] = id
+		}
+		in := "(" + strings.Join(placeholders, ", ") + ")"
+
+		err = impl.tx(ctx, func(tx *ImplStorageProductMySQL) (err error) {
+			// lock the products and check they exist
+			var rows *sql.Rows
+			rows, err = tx.ex.QueryContext(ctx, "SELECT id FROM products WHERE id IN "+in+" FOR UPDATE", args...)
+			if err != nil {
+				err = fmt.Errorf("%w. %v", ErrStorageProductInternal, err)
+				return
+			}
+			exists := make(map[int]bool, len(ids))
+			for rows.Next() {
+				var id int
+				if err = rows.Scan(&id); err != nil {
+					rows.Close()
+					err = fmt.Errorf("%w. %v", ErrStorageProductInternal, err)
+					return
+				}
+				exists[id] = true
+			}
+			rows.Close()
+			if rows.Err() != nil {
+				err = fmt.Errorf("%w. %v", ErrStorageProductInternal, rows.Err())
+				return
+			}
+
+			// ids deleted twice in the batch are not found the second time
+			for i, id := range ids {
+				if !exists[id] {
+					errs[i] = fmt.Errorf("%w. id %d", ErrStorageProductNotFound, id)
+					err = errs[i]
+				}
+				delete(exists, id)
+			}
+			if err != nil {
+				return
+			}
+
+			// delete
+			_, err = tx.ex.ExecContext(ctx, "DELETE FROM products WHERE id IN "+in, args...)
+			if err != nil {
+				err = fmt.Errorf("%w. %v", ErrStorageProductInternal, err)
+				return
+			}
+			return
+		})
+		if err != nil {
+			err = abortBatch(errs, err)
+		}
+		return
+	}
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Tests for queryProductMySQL function
+func TestQueryProductMySQL(t *testing.T) {
+	typ := "fruit"
+	minPrice := 1.5
+	maxCount := 10
+
+	type input struct { q *QueryProduct }
+	type output struct { where string; args []any; orderBy string; err error }
+	type testCase struct {
+		name string
+		input input
+		output output
+	}
+
+	cases := []testCase{
+		// valid cases
+		{
+			name: "empty query sorts by id",
+			input: input{q: &QueryProduct{}},
+			output: output{where: "", args: nil, orderBy: " ORDER BY id ASC", err: nil},
+		},
+		{
+			name: "filters are passed as arguments",
+			input: input{q: &QueryProduct{Filter: FilterProduct{Type: &typ, MinPrice: &minPrice, MaxCount: &maxCount, Name: "50%_off"}}},
+			output: output{
+				where: " WHERE type = ? AND price >= ? AND count <= ? AND name LIKE ?",
+				args: []any{"fruit", 1.5, 10, `%50\%\_off%`},
+				orderBy: " ORDER BY id ASC",
+				err: nil,
+			},
+		},
+		{
+			name: "sort by several fields",
+			input: input{q: &QueryProduct{Sort: []SortProduct{{Field: FieldProductType}, {Field: FieldProductPrice, Desc: true}}}},
+			output: output{where: "", args: nil, orderBy: " ORDER BY type ASC, price DESC, id ASC", err: nil},
+		},
+		{
+			name: "sort by id does not add the tie-breaker",
+			input: input{q: &QueryProduct{Sort: []SortProduct{{Field: FieldProductID, Desc: true}}}},
+			output: output{where: "", args: nil, orderBy: " ORDER BY id DESC", err: nil},
+		},
+
+		// invalid cases
+		{
+			name: "sort by field out of the allowlist",
+			input: input{q: &QueryProduct{Sort: []SortProduct{{Field: FieldProduct("id; DROP TABLE products")}}}},
+			output: output{where: "", args: nil, orderBy: "", err: ErrStorageProductQueryInvalid},
+		},
+	}
+
+	// run tests
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// arrange
+			// ...
+
+			// act
+			where, args, orderBy, err := queryProductMySQL(c.input.q)
+
+			// assert
+			if c.output.err != nil {
+				require.ErrorIs(t, err, c.output.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.output.where, where)
+			require.Equal(t, c.output.args, args)
+			require.Equal(t, c.output.orderBy, orderBy)
+		})
+	}
+}
@@ -0,0 +1,663 @@
+// Package storagetest provides a conformance test suite for storage.StorageProduct implementations
+package storagetest
+
+import (
+	"app/internal/products/storage"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// FactoryStorageProduct returns an empty StorageProduct for a test
+// - it is called once per test, so each test starts from a clean storage
+type FactoryStorageProduct func(t *testing.T) storage.StorageProduct
+
+// Run runs the StorageProduct contract tests against the storages built by factory
+func Run(t *testing.T, factory FactoryStorageProduct) {
+	ctx := context.Background()
+
+	t.Run("Store assigns an id", func(t *testing.T) {
+		// arrange
+		st := factory(t)
+
+		// act
+		p1 := newProduct("apple", "fruit", 1, 1.5)
+		err1 := st.Store(ctx, p1)
+		p2 := newProduct("banana", "fruit", 2, 2.5)
+		err2 := st.Store(ctx, p2)
+
+		// assert
+		require.NoError(t, err1)
+		require.NoError(t, err2)
+		require.NotZero(t, p1.ID)
+		require.NotZero(t, p2.ID)
+		require.Greater(t, p2.ID, p1.ID)
+		require.Equal(t, 1, p1.Version)
+		require.Equal(t, 1, p2.Version)
+		require.False(t, p1.UpdatedAt.IsZero())
+		require.False(t, p2.UpdatedAt.Before(p1.UpdatedAt))
+	})
+
+	t.Run("Store not unique", func(t *testing.T) {
+		// arrange
+		st := factory(t)
+		require.NoError(t, st.Store(ctx, newProduct("apple", "fruit", 1, 1.5)))
+
+		// act
+		err := st.Store(ctx, newProduct("apple", "other", 2, 2.5))
+
+		// assert
+		require.ErrorIs(t, err, storage.ErrStorageProductNotUnique)
+	})
+
+	t.Run("GetOne round-trips every field", func(t *testing.T) {
+		// arrange
+		st := factory(t)
+		p := newProduct("apple", "fruit", 3, 1.5)
+		require.NoError(t, st.Store(ctx, p))
+
+		// act
+		product, err := st.GetOne(ctx, p.ID)
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, p, product)
+	})
+
+	t.Run("GetOne round-trips zero and unknown values", func(t *testing.T) {
+		// arrange
+		st := factory(t)
+		zero := 0
+		zeroPrice := 0.0
+		p1 := &storage.Product{Name: "free", Type: nil, Count: &zero, Price: &zeroPrice}
+		require.NoError(t, st.Store(ctx, p1))
+		p2 := &storage.Product{Name: "unknown", Type: nil, Count: nil, Price: nil}
+		require.NoError(t, st.Store(ctx, p2))
+
+		// act
+		product1, err1 := st.GetOne(ctx, p1.ID)
+		product2, err2 := st.GetOne(ctx, p2.ID)
+
+		// assert
+		require.NoError(t, err1)
+		require.Equal(t, p1, product1)
+		require.NoError(t, err2)
+		require.Equal(t, p2, product2)
+	})
+
+	t.Run("GetOne not found", func(t *testing.T) {
+		// arrange
+		st := factory(t)
+
+		// act
+		product, err := st.GetOne(ctx, 1)
+
+		// assert
+		require.ErrorIs(t, err, storage.ErrStorageProductNotFound)
+		require.Nil(t, product)
+	})
+
+	t.Run("GetAll filters, sorts and paginates", func(t *testing.T) {
+		// arrange
+		st := factory(t)
+		fruit := "fruit"
+		minPrice := 2.0
+		products := []*storage.Product{
+			newProduct("apple", "fruit", 1, 1.5),
+			newProduct("banana", "fruit", 2, 2.5),
+			newProduct("carrot", "vegetable", 3, 3.5),
+			newProduct("cherry", "fruit", 4, 4.5),
+		}
+		for _, p := range products {
+			require.NoError(t, st.Store(ctx, p))
+		}
+
+		// act
+		q := &storage.QueryProduct{
+			Filter: storage.FilterProduct{Type: &fruit, MinPrice: &minPrice},
+			Sort:   []storage.SortProduct{{Field: storage.FieldProductPrice, Desc: true}},
+			Limit:  1,
+			Offset: 1,
+		}
+		ps, total, err := st.GetAll(ctx, q)
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, 2, total)
+		require.Equal(t, []*storage.Product{products[1]}, ps)
+	})
+
+	t.Run("GetAll name substring", func(t *testing.T) {
+		// arrange
+		st := factory(t)
+		require.NoError(t, st.Store(ctx, newProduct("green apple", "fruit", 1, 1.5)))
+		require.NoError(t, st.Store(ctx, newProduct("banana", "fruit", 2, 2.5)))
+
+		// act
+		ps, total, err := st.GetAll(ctx, &storage.QueryProduct{Filter: storage.FilterProduct{Name: "apple"}, Limit: 10})
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, 1, total)
+		require.Len(t, ps, 1)
+		require.Equal(t, "green apple", ps[0].Name)
+	})
+
+	t.Run("GetAll unknown values do not match filters", func(t *testing.T) {
+		// arrange
+		st := factory(t)
+		zero := 0.0
+		require.NoError(t, st.Store(ctx, &storage.Product{Name: "free", Price: &zero}))
+		require.NoError(t, st.Store(ctx, &storage.Product{Name: "unknown"}))
+
+		// act
+		ps, total, err := st.GetAll(ctx, &storage.QueryProduct{Filter: storage.FilterProduct{MaxPrice: &zero}, Limit: 10})
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, 1, total)
+		require.Len(t, ps, 1)
+		require.Equal(t, "free", ps[0].Name)
+	})
+
+	t.Run("GetAll invalid sort field", func(t *testing.T) {
+		// arrange
+		st := factory(t)
+
+		// act
+		_, _, err := st.GetAll(ctx, &storage.QueryProduct{Sort: []storage.SortProduct{{Field: "unknown"}}, Limit: 10})
+
+		// assert
+		require.ErrorIs(t, err, storage.ErrStorageProductQueryInvalid)
+	})
+
+	t.Run("Each iterates the matching products by id", func(t *testing.T) {
+		// arrange
+		st := factory(t)
+		fruit := "fruit"
+		products := []*storage.Product{
+			newProduct("banana", "fruit", 2, 2.5),
+			newProduct("carrot", "vegetable", 3, 3.5),
+			newProduct("apple", "fruit", 1, 1.5),
+		}
+		for _, p := range products {
+			require.NoError(t, st.Store(ctx, p))
+		}
+
+		// act
+		var ps []*storage.Product
+		err := st.Each(ctx, &storage.FilterProduct{Type: &fruit}, func(p *storage.Product) error {
+			ps = append(ps, p)
+			return nil
+		})
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, []*storage.Product{products[0], products[2]}, ps)
+	})
+
+	t.Run("Each stops at the first error", func(t *testing.T) {
+		// arrange
+		st := factory(t)
+		require.NoError(t, st.Store(ctx, newProduct("apple", "fruit", 1, 1.5)))
+		require.NoError(t, st.Store(ctx, newProduct("banana", "fruit", 2, 2.5)))
+		errStop := errors.New("stop")
+
+		// act
+		var calls int
+		err := st.Each(ctx, &storage.FilterProduct{}, func(p *storage.Product) error {
+			calls++
+			return errStop
+		})
+
+		// assert
+		require.ErrorIs(t, err, errStop)
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("Each canceled context", func(t *testing.T) {
+		// arrange
+		st := factory(t)
+		require.NoError(t, st.Store(ctx, newProduct("apple", "fruit", 1, 1.5)))
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		// act
+		var calls int
+		err := st.Each(canceled, &storage.FilterProduct{}, func(p *storage.Product) error {
+			calls++
+			return nil
+		})
+
+		// assert
+		require.ErrorIs(t, err, storage.ErrStorageProductInternal)
+		require.Equal(t, 0, calls)
+	})
+
+	t.Run("Update round-trips every field", func(t *testing.T) {
+		// arrange
+		st := factory(t)
+		p := newProduct("apple", "fruit", 1, 1.5)
+		require.NoError(t, st.Store(ctx, p))
+
+		// act
+		update := newProductWithID(p.ID, "green apple", "organic", 5, 9.5)
+		err := st.Update(ctx, update)
+
+		// assert
+		require.NoError(t, err)
+		product, err := st.GetOne(ctx, p.ID)
+		require.NoError(t, err)
+		require.Equal(t, update, product)
+	})
+
+	t.Run("Update without changes", func(t *testing.T) {
+		// arrange
+		st := factory(t)
+		p := newProduct("apple", "fruit", 1, 1.5)
+		require.NoError(t, st.Store(ctx, p))
+
+		// act
+		err := st.Update(ctx, newProductWithID(p.ID, "apple", "fruit", 1, 1.5))
+
+		// assert
+		require.NoError(t, err)
+	})
+
+	t.Run("Update increments the version", func(t *testing.T) {
+		// arrange
+		st := factory(t)
+		p := newProduct("apple", "fruit", 1, 1.5)
+		require.NoError(t, st.Store(ctx, p))
+
+		// act
+		update := newProductWithID(p.ID, "apple", "fruit", 2, 1.5)
+		update.Version = p.Version
+		err1 := st.Update(ctx, update)
+		err2 := st.Update(ctx, newProductWithID(p.ID, "apple", "fruit", 3, 1.5))
+
+		// assert
+		require.NoError(t, err1)
+		require.Equal(t, 2, update.Version)
+		require.False(t, update.UpdatedAt.Before(p.UpdatedAt))
+		require.NoError(t, err2)
+		product, err := st.GetOne(ctx, p.ID)
+		require.NoError(t, err)
+		require.Equal(t, 3, product.Version)
+		require.False(t, product.UpdatedAt.Before(update.UpdatedAt))
+	})
+
+	t.Run("Update version mismatch", func(t *testing.T) {
+		// arrange
+		st := factory(t)
+		p := newProduct("apple", "fruit", 1, 1.5)
+		require.NoError(t, st.Store(ctx, p))
+		require.NoError(t, st.Update(ctx, newProductWithID(p.ID, "apple", "fruit", 2, 1.5)))
+
+		// act
+		stale := newProductWithID(p.ID, "apple", "fruit", 3, 1.5)
+		stale.Version = p.Version
+		err := st.Update(ctx, stale)
+
+		// assert
+		require.ErrorIs(t, err, storage.ErrStorageProductVersionMismatch)
+		product, err := st.GetOne(ctx, p.ID)
+		require.NoError(t, err)
+		require.Equal(t, 2, *product.Count)
+	})
+
+	t.Run("Update with version not found", func(t *testing.T) {
+		// arrange
+		st := factory(t)
+
+		// act
+		p := newProductWithID(1, "apple", "fruit", 1, 1.5)
+		p.Version = 1
+		err := st.Update(ctx, p)
+
+		// assert
+		require.ErrorIs(t, err, storage.ErrStorageProductNotFound)
+	})
+
+	t.Run("Update not found", func(t *testing.T) {
+		// arrange
+		st := factory(t)
+
+		// act
+		err := st.Update(ctx, newProductWithID(1, "apple", "fruit", 1, 1.5))
+
+		// assert
+		require.ErrorIs(t, err, storage.ErrStorageProductNotFound)
+	})
+
+	t.Run("Update not unique", func(t *testing.T) {
+		// arrange
+		st := factory(t)
+		require.NoError(t, st.Store(ctx, newProduct("apple", "fruit", 1, 1.5)))
+		p := newProduct("banana", "fruit", 2, 2.5)
+		require.NoError(t, st.Store(ctx, p))
+
+		// act
+		err := st.Update(ctx, newProductWithID(p.ID, "apple", "fruit", 2, 2.5))
+
+		// assert
+		require.ErrorIs(t, err, storage.ErrStorageProductNotUnique)
+	})
+
+	t.Run("Delete removes the product", func(t *testing.T) {
+		// arrange
+		st := factory(t)
+		p := newProduct("apple", "fruit", 1, 1.5)
+		require.NoError(t, st.Store(ctx, p))
+
+		// act
+		err := st.Delete(ctx, p.ID, 0)
+
+		// assert
+		require.NoError(t, err)
+		_, err = st.GetOne(ctx, p.ID)
+		require.ErrorIs(t, err, storage.ErrStorageProductNotFound)
+	})
+
+	t.Run("Delete with version", func(t *testing.T) {
+		// arrange
+		st := factory(t)
+		p := newProduct("apple", "fruit", 1, 1.5)
+		require.NoError(t, st.Store(ctx, p))
+
+		// act
+		err := st.Delete(ctx, p.ID, p.Version)
+
+		// assert
+		require.NoError(t, err)
+	})
+
+	t.Run("Delete version mismatch", func(t *testing.T) {
+		// arrange
+		st := factory(t)
+		p := newProduct("apple", "fruit", 1, 1.5)
+		require.NoError(t, st.Store(ctx, p))
+
+		// act
+		err := st.Delete(ctx, p.ID, p.Version+1)
+
+		// assert
+		require.ErrorIs(t, err, storage.ErrStorageProductVersionMismatch)
+		_, err = st.GetOne(ctx, p.ID)
+		require.NoError(t, err)
+	})
+
+	t.Run("Delete twice is not found", func(t *testing.T) {
+		// arrange
+		st := factory(t)
+		p := newProduct("apple", "fruit", 1, 1.5)
+		require.NoError(t, st.Store(ctx, p))
+		require.NoError(t, st.Delete(ctx, p.ID, 0))
+
+		// act
+		err := st.Delete(ctx, p.ID, 0)
+
+		// assert
+		require.ErrorIs(t, err, storage.ErrStorageProductNotFound)
+	})
+
+	t.Run("StoreMany atomic stores every product", func(t *testing.T) {
+		// arrange
+		st := factory(t)
+		ps := []*storage.Product{newProduct("apple", "fruit", 1, 1.5), newProduct("banana", "fruit", 2, 2.5)}
+
+		// act
+		errs, err := st.StoreMany(ctx, ps, storage.BatchModeAtomic)
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, []error{nil, nil}, errs)
+		for _, p := range ps {
+			require.NotZero(t, p.ID)
+			require.Equal(t, 1, p.Version)
+			product, err := st.GetOne(ctx, p.ID)
+			require.NoError(t, err)
+			require.Equal(t, p, product)
+		}
+	})
+
+	t.Run("StoreMany atomic stores nothing on failure", func(t *testing.T) {
+		// arrange
+		st := factory(t)
+		require.NoError(t, st.Store(ctx, newProduct("apple", "fruit", 1, 1.5)))
+		ps := []*storage.Product{newProduct("banana", "fruit", 2, 2.5), newProduct("apple", "fruit", 3, 3.5)}
+
+		// act
+		errs, err := st.StoreMany(ctx, ps, storage.BatchModeAtomic)
+
+		// assert
+		require.ErrorIs(t, err, storage.ErrStorageProductNotUnique)
+		require.ErrorIs(t, errs[0], storage.ErrStorageProductRolledBack)
+		require.ErrorIs(t, errs[1], storage.ErrStorageProductNotUnique)
+		require.Zero(t, ps[0].ID)
+		_, total, err := st.GetAll(ctx, &storage.QueryProduct{Limit: 10})
+		require.NoError(t, err)
+		require.Equal(t, 1, total)
+	})
+
+	t.Run("StoreMany best effort stores the valid products", func(t *testing.T) {
+		// arrange
+		st := factory(t)
+		ps := []*storage.Product{newProduct("apple", "fruit", 1, 1.5), newProduct("apple", "fruit", 2, 2.5), newProduct("banana", "fruit", 3, 3.5)}
+
+		// act
+		errs, err := st.StoreMany(ctx, ps, storage.BatchModeBestEffort)
+
+		// assert
+		require.NoError(t, err)
+		require.NoError(t, errs[0])
+		require.ErrorIs(t, errs[1], storage.ErrStorageProductNotUnique)
+		require.NoError(t, errs[2])
+		require.Zero(t, ps[1].ID)
+		for _, p := range []*storage.Product{ps[0], ps[2]} {
+			product, err := st.GetOne(ctx, p.ID)
+			require.NoError(t, err)
+			require.Equal(t, p, product)
+		}
+		_, total, err := st.GetAll(ctx, &storage.QueryProduct{Limit: 10})
+		require.NoError(t, err)
+		require.Equal(t, 2, total)
+	})
+
+	t.Run("StoreMany best effort without failures stores every product", func(t *testing.T) {
+		// arrange
+		st := factory(t)
+		ps := []*storage.Product{newProduct("apple", "fruit", 1, 1.5), newProduct("banana", "fruit", 2, 2.5), newProduct("cherry", "fruit", 3, 3.5)}
+
+		// act
+		errs, err := st.StoreMany(ctx, ps, storage.BatchModeBestEffort)
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, []error{nil, nil, nil}, errs)
+		for _, p := range ps {
+			require.NotZero(t, p.ID)
+			require.Equal(t, 1, p.Version)
+			product, err := st.GetOne(ctx, p.ID)
+			require.NoError(t, err)
+			require.Equal(t, p, product)
+		}
+	})
+
+	t.Run("UpdateMany atomic updates nothing on failure", func(t *testing.T) {
+		// arrange
+		st := factory(t)
+		p := newProduct("apple", "fruit", 1, 1.5)
+		require.NoError(t, st.Store(ctx, p))
+
+		// act
+		ps := []*storage.Product{newProductWithID(p.ID, "apple", "fruit", 5, 1.5), newProductWithID(p.ID+1, "banana", "fruit", 2, 2.5)}
+		errs, err := st.UpdateMany(ctx, ps, storage.BatchModeAtomic)
+
+		// assert
+		require.ErrorIs(t, err, storage.ErrStorageProductNotFound)
+		require.ErrorIs(t, errs[0], storage.ErrStorageProductRolledBack)
+		require.ErrorIs(t, errs[1], storage.ErrStorageProductNotFound)
+		product, err := st.GetOne(ctx, p.ID)
+		require.NoError(t, err)
+		require.Equal(t, p, product)
+	})
+
+	t.Run("UpdateMany best effort updates the valid products", func(t *testing.T) {
+		// arrange
+		st := factory(t)
+		p := newProduct("apple", "fruit", 1, 1.5)
+		require.NoError(t, st.Store(ctx, p))
+
+		// act
+		ps := []*storage.Product{newProductWithID(p.ID+1, "banana", "fruit", 2, 2.5), newProductWithID(p.ID, "apple", "fruit", 5, 1.5)}
+		errs, err := st.UpdateMany(ctx, ps, storage.BatchModeBestEffort)
+
+		// assert
+		require.NoError(t, err)
+		require.ErrorIs(t, errs[0], storage.ErrStorageProductNotFound)
+		require.NoError(t, errs[1])
+		require.Equal(t, 2, ps[1].Version)
+		product, err := st.GetOne(ctx, p.ID)
+		require.NoError(t, err)
+		require.Equal(t, ps[1], product)
+	})
+
+	t.Run("DeleteMany atomic deletes nothing on failure", func(t *testing.T) {
+		// arrange
+		st := factory(t)
+		p := newProduct("apple", "fruit", 1, 1.5)
+		require.NoError(t, st.Store(ctx, p))
+
+		// act
+		errs, err := st.DeleteMany(ctx, []int{p.ID, p.ID + 1}, storage.BatchModeAtomic)
+
+		// assert
+		require.ErrorIs(t, err, storage.ErrStorageProductNotFound)
+		require.ErrorIs(t, errs[0], storage.ErrStorageProductRolledBack)
+		require.ErrorIs(t, errs[1], storage.ErrStorageProductNotFound)
+		_, err = st.GetOne(ctx, p.ID)
+		require.NoError(t, err)
+	})
+
+	t.Run("DeleteMany best effort deletes the existing products", func(t *testing.T) {
+		// arrange
+		st := factory(t)
+		p1 := newProduct("apple", "fruit", 1, 1.5)
+		require.NoError(t, st.Store(ctx, p1))
+		p2 := newProduct("banana", "fruit", 2, 2.5)
+		require.NoError(t, st.Store(ctx, p2))
+
+		// act
+		errs, err := st.DeleteMany(ctx, []int{p1.ID, p1.ID, p2.ID}, storage.BatchModeBestEffort)
+
+		// assert
+		require.NoError(t, err)
+		require.NoError(t, errs[0])
+		require.ErrorIs(t, errs[1], storage.ErrStorageProductNotFound)
+		require.NoError(t, errs[2])
+		_, total, err := st.GetAll(ctx, &storage.QueryProduct{Limit: 10})
+		require.NoError(t, err)
+		require.Equal(t, 0, total)
+	})
+
+	t.Run("WithTx commits", func(t *testing.T) {
+		// arrange
+		st := factory(t)
+		p := newProduct("apple", "fruit", 1, 1.5)
+		require.NoError(t, st.Store(ctx, p))
+
+		// act
+		err := st.WithTx(ctx, func(tx storage.StorageProduct) error {
+			product, err := tx.GetOne(ctx, p.ID)
+			if err != nil {
+				return err
+			}
+			*product.Count++
+			if err = tx.Update(ctx, product); err != nil {
+				return err
+			}
+			return tx.Store(ctx, newProduct("banana", "fruit", 2, 2.5))
+		})
+
+		// assert
+		require.NoError(t, err)
+		product, err := st.GetOne(ctx, p.ID)
+		require.NoError(t, err)
+		require.Equal(t, 2, *product.Count)
+		require.Equal(t, 2, product.Version)
+		_, total, err := st.GetAll(ctx, &storage.QueryProduct{Limit: 10})
+		require.NoError(t, err)
+		require.Equal(t, 2, total)
+	})
+
+	t.Run("WithTx rolls back on error", func(t *testing.T) {
+		// arrange
+		st := factory(t)
+		p := newProduct("apple", "fruit", 1, 1.5)
+		require.NoError(t, st.Store(ctx, p))
+		errFn := errors.New("fn failed")
+
+		// act
+		err := st.WithTx(ctx, func(tx storage.StorageProduct) error {
+			if err := tx.Update(ctx, newProductWithID(p.ID, "apple", "fruit", 5, 1.5)); err != nil {
+				return err
+			}
+			if err := tx.Delete(ctx, p.ID, 0); err != nil {
+				return err
+			}
+			if err := tx.Store(ctx, newProduct("banana", "fruit", 2, 2.5)); err != nil {
+				return err
+			}
+			return errFn
+		})
+
+		// assert
+		require.ErrorIs(t, err, errFn)
+		ps, total, err := st.GetAll(ctx, &storage.QueryProduct{Limit: 10})
+		require.NoError(t, err)
+		require.Equal(t, 1, total)
+		require.Equal(t, []*storage.Product{p}, ps)
+	})
+
+	t.Run("WithTx nested rolls back only its own operations", func(t *testing.T) {
+		// arrange
+		st := factory(t)
+
+		// act
+		err := st.WithTx(ctx, func(tx storage.StorageProduct) error {
+			if err := tx.Store(ctx, newProduct("apple", "fruit", 1, 1.5)); err != nil {
+				return err
+			}
+			errNested := tx.WithTx(ctx, func(tx storage.StorageProduct) error {
+				if err := tx.Store(ctx, newProduct("banana", "fruit", 2, 2.5)); err != nil {
+					return err
+				}
+				return tx.Store(ctx, newProduct("apple", "fruit", 3, 3.5))
+			})
+			require.ErrorIs(t, errNested, storage.ErrStorageProductNotUnique)
+			return nil
+		})
+
+		// assert
+		require.NoError(t, err)
+		ps, total, err := st.GetAll(ctx, &storage.QueryProduct{Limit: 10})
+		require.NoError(t, err)
+		require.Equal(t, 1, total)
+		require.Equal(t, "apple", ps[0].Name)
+	})
+}
+
+// newProduct returns a product with every optional field set
+func newProduct(name string, typ string, count int, price float64) *storage.Product {
+	return &storage.Product{Name: name, Type: &typ, Count: &count, Price: &price}
+}
+
+// newProductWithID returns a product with the id and every optional field set
+func newProductWithID(id int, name string, typ string, count int, price float64) *storage.Product {
+	p := newProduct(name, typ, count, price)
+	p.ID = id
+	return p
+}
@@ -0,0 +1,149 @@
+package validator
+
+import (
+	"app/internal/products/storage"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldError is a validation error on a product field
+type FieldError struct {
+	// Field is the json name of the field
+	Field	string	`json:"field"`
+	// Message describes why the field is invalid
+	Message	string	`json:"message"`
+}
+
+// ErrorValidatorProduct is returned when a product has one or more invalid fields
+// - errors.Is(err, ErrValidatorProductInvalid) reports true
+type ErrorValidatorProduct struct {
+	// Fields are all the failing fields
+	Fields []FieldError
+}
+
+// Error returns the error message
+func (e *ErrorValidatorProduct) Error() string {
+	msgs := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", f.Field, f.Message))
+	}
+	return fmt.Sprintf("%s. %s", ErrValidatorProductInvalid, strings.Join(msgs, "; "))
+}
+
+// Is reports whether target is ErrValidatorProductInvalid
+func (e *ErrorValidatorProduct) Is(target error) bool {
+	return target == ErrValidatorProductInvalid
+}
+
+var (
+	ErrValidatorProductInvalid = errors.New("validator product invalid")
+)
+
+// ValidatorProduct is an interface for product validation
+type ValidatorProduct interface {
+	// Validate returns an *ErrorValidatorProduct listing every invalid field of p
+	Validate(p *storage.Product) (err error)
+}
+
+// ConfigValidatorProduct is the configuration of ImplValidatorProduct
+type ConfigValidatorProduct struct {
+	// NameMaxLength is the max amount of characters of the name
+	NameMaxLength int
+	// TypeMaxLength is the max amount of characters of the type
+	TypeMaxLength int
+	// Types is the set of allowed types (empty allows any type)
+	Types []string
+}
+
+// NewImplValidatorProduct returns new ImplValidatorProduct
+// - cfg can be nil or have zero values, in that case defaults are used
+func NewImplValidatorProduct(cfg *ConfigValidatorProduct) *ImplValidatorProduct {
+	// default config
+	defaultCfg := ConfigValidatorProduct{
+		NameMaxLength: 100,
+		TypeMaxLength: 50,
+	}
+	if cfg != nil {
+		if cfg.NameMaxLength > 0 {
+			defaultCfg.NameMaxLength = cfg.NameMaxLength
+		}
+		if cfg.TypeMaxLength > 0 {
+			defaultCfg.TypeMaxLength = cfg.TypeMaxLength
+		}
+		defaultCfg.Types = cfg.Types
+	}
+
+	return &ImplValidatorProduct{cfg: defaultCfg}
+}
+
+// ImplValidatorProduct is an implementation of ValidatorProduct interface
+type ImplValidatorProduct struct {
+	// cfg is the configuration of the validator
+	cfg ConfigValidatorProduct
+}
+
+// maxCount is the max count that can be stored (signed 32 bits column)
+const maxCount = 1<<31 - 1
+
+// Validate returns an *ErrorValidatorProduct listing every invalid field of p
+// - name is required, type, count and price are optional
+func (v *ImplValidatorProduct) Validate(p *storage.Product) (err error) {
+	var fields []FieldError
+
+	// name
+	switch {
+	case strings.TrimSpace((*p).Name) == "":
+		fields = append(fields, FieldError{Field: "name", Message: "is required"})
+	case utf8.RuneCountInString((*p).Name) > v.cfg.NameMaxLength:
+		fields = append(fields, FieldError{Field: "name", Message: fmt.Sprintf("must be at most %d characters", v.cfg.NameMaxLength)})
+	}
+
+	// type
+	if (*p).Type != nil {
+		switch {
+		case utf8.RuneCountInString(*(*p).Type) > v.cfg.TypeMaxLength:
+			fields = append(fields, FieldError{Field: "type", Message: fmt.Sprintf("must be at most %d characters", v.cfg.TypeMaxLength)})
+		case len(v.cfg.Types) > 0 && !v.allowedType(*(*p).Type):
+			fields = append(fields, FieldError{Field: "type", Message: fmt.Sprintf("must be one of %s", strings.Join(v.cfg.Types, ", "))})
+		}
+	}
+
+	// count
+	if (*p).Count != nil {
+		switch {
+		case *(*p).Count < 0:
+			fields = append(fields, FieldError{Field: "count", Message: "must be non-negative"})
+		case *(*p).Count > maxCount:
+			fields = append(fields, FieldError{Field: "count", Message: fmt.Sprintf("must be at most %d", maxCount)})
+		}
+	}
+
+	// price
+	if (*p).Price != nil {
+		switch {
+		case math.IsNaN(*(*p).Price) || math.IsInf(*(*p).Price, 0):
+			// NaN < 0 is false, and neither can be serialized to json
+			fields = append(fields, FieldError{Field: "price", Message: "must be a finite number"})
+		case *(*p).Price < 0:
+			fields = append(fields, FieldError{Field: "price", Message: "must be non-negative"})
+		}
+	}
+
+	if len(fields) > 0 {
+		err = &ErrorValidatorProduct{Fields: fields}
+	}
+	return
+}
+
+// allowedType reports whether t is in the set of allowed types
+func (v *ImplValidatorProduct) allowedType(t string) bool {
+	for _, allowed := range v.cfg.Types {
+		if t == allowed {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package validator
+
+import (
+	"app/internal/products/storage"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Tests for ImplValidatorProduct.Validate method
+func TestImplValidatorProduct_Validate(t *testing.T) {
+	typ := func(s string) *string { return &s }
+	count := func(i int) *int { return &i }
+	price := func(f float64) *float64 { return &f }
+
+	type input struct { cfg *ConfigValidatorProduct; p *storage.Product }
+	type output struct { fields []FieldError }
+	type testCase struct {
+		name string
+		input input
+		output output
+	}
+
+	cases := []testCase{
+		// valid cases
+		{
+			name: "valid product with every field",
+			input: input{cfg: &ConfigValidatorProduct{Types: []string{"fruit"}}, p: &storage.Product{Name: "apple", Type: typ("fruit"), Count: count(1), Price: price(1.5)}},
+			output: output{fields: nil},
+		},
+		{
+			name: "valid product with zero and unknown values",
+			input: input{cfg: nil, p: &storage.Product{Name: "apple", Type: nil, Count: count(0), Price: price(0)}},
+			output: output{fields: nil},
+		},
+
+		// invalid cases
+		{
+			name: "every field invalid",
+			input: input{cfg: &ConfigValidatorProduct{Types: []string{"fruit", "vegetable"}}, p: &storage.Product{Name: " ", Type: typ("meat"), Count: count(-1), Price: price(-0.5)}},
+			output: output{fields: []FieldError{
+				{Field: "name", Message: "is required"},
+				{Field: "type", Message: "must be one of fruit, vegetable"},
+				{Field: "count", Message: "must be non-negative"},
+				{Field: "price", Message: "must be non-negative"},
+			}},
+		},
+		{
+			name: "price NaN",
+			input: input{cfg: nil, p: &storage.Product{Name: "apple", Price: price(math.NaN())}},
+			output: output{fields: []FieldError{{Field: "price", Message: "must be a finite number"}}},
+		},
+		{
+			name: "price +Inf",
+			input: input{cfg: nil, p: &storage.Product{Name: "apple", Price: price(math.Inf(1))}},
+			output: output{fields: []FieldError{{Field: "price", Message: "must be a finite number"}}},
+		},
+		{
+			name: "price -Inf",
+			input: input{cfg: nil, p: &storage.Product{Name: "apple", Price: price(math.Inf(-1))}},
+			output: output{fields: []FieldError{{Field: "price", Message: "must be a finite number"}}},
+		},
+		{
+			name: "strings too long",
+			input: input{cfg: &ConfigValidatorProduct{NameMaxLength: 3, TypeMaxLength: 2}, p: &storage.Product{Name: "ápple", Type: typ(strings.Repeat("x", 3))}},
+			output: output{fields: []FieldError{
+				{Field: "name", Message: "must be at most 3 characters"},
+				{Field: "type", Message: "must be at most 2 characters"},
+			}},
+		},
+	}
+
+	// run tests
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// arrange
+			v := NewImplValidatorProduct(c.input.cfg)
+
+			// act
+			err := v.Validate(c.input.p)
+
+			// assert
+			if c.output.fields == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, ErrValidatorProductInvalid)
+			var errValidation *ErrorValidatorProduct
+			require.ErrorAs(t, err, &errValidation)
+			require.Equal(t, c.output.fields, errValidation.Fields)
+		})
+	}
+}
@@ -0,0 +1,400 @@
+// Package config loads a configuration struct from a file, env vars and command-line flags
+// - precedence, from lowest to highest: defaults (the values of the struct before loading), file, env vars, flags
+// - the file is YAML (.yaml, .yml) or JSON (.json), its path is given by the -config flag or the CONFIG_FILE env var
+// - fields are described by tags:
+//   config:"key[,secret]" is the key of the field in the file and in the printed config (secret values are redacted)
+//   env:"NAME" is the env var of the field (an empty env var is ignored)
+//   flag:"name" is the command-line flag of the field, usage:"text" its help
+// - field types: string, int, int64, float64, bool, time.Duration, []string (comma separated in env vars and flags)
+//   and structs or pointers to structs, which are sections of the file (embedded structs are merged in their parent)
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrConfigInternal = errors.New("internal config error")
+	ErrConfigInvalid = errors.New("config invalid")
+)
+
+// Validator is implemented by configurations that check their values once loaded
+type Validator interface {
+	// Validate returns an error describing the invalid values
+	Validate() (err error)
+}
+
+const (
+	// FlagFile is the flag with the path of the config file
+	FlagFile = "config"
+	// EnvFile is the env var with the path of the config file
+	EnvFile = "CONFIG_FILE"
+	// redacted replaces the values of secret fields when printing
+	redacted = "[redacted]"
+)
+
+// field is a configurable field of a configuration struct
+type field struct {
+	// key is the dotted path of the field (example: db.user)
+	key		string
+	// env is the env var of the field
+	env		string
+	// flag is the command-line flag of the field
+	flag	string
+	// usage is the help of the flag
+	usage	string
+	// secret fields are redacted when printing
+	secret	bool
+	// value is the settable value of the field
+	value	reflect.Value
+}
+
+// Load loads cfg, a pointer to a struct with its defaults, from the file, env vars and flags
+// - args are the command-line arguments without the program name, lookupEnv is usually os.LookupEnv
+// - rest are the arguments after the flags
+// - flag.ErrHelp if args ask for help (the usage is printed to stderr)
+// - ErrConfigInvalid if a value can not be parsed, the file has an unknown key or cfg.Validate fails
+func Load(cfg any, args []string, lookupEnv func(key string) (value string, ok bool)) (rest []string, err error) {
+	fields, err := fieldsOf(cfg)
+	if err != nil {
+		return
+	}
+
+	// flags (parsed first since they name the file, applied last)
+	fs := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	file := fs.String(FlagFile, "", "path of the config file (YAML or JSON), also env "+EnvFile)
+	type setFlag struct { f *field; value string }
+	var setFlags []setFlag
+	for i := range fields {
+		f := &fields[i]
+		if f.flag == "" {
+			continue
+		}
+		usage := f.usage
+		if f.env != "" {
+			usage = fmt.Sprintf("%s (env %s)", usage, f.env)
+		}
+		fs.Var(&valueFlag{
+			def: format(f.value),
+			isBool: f.value.Kind() == reflect.Bool,
+			set: func(s string) error {
+				setFlags = append(setFlags, setFlag{f: f, value: s})
+				// check the value now so the error names the flag
+				return set(reflect.New(f.value.Type()).Elem(), s)
+			},
+		}, f.flag, usage)
+	}
+	err = fs.Parse(args)
+	if err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			err = fmt.Errorf("%w. %v", ErrConfigInvalid, err)
+		}
+		return
+	}
+	rest = fs.Args()
+
+	// file
+	if *file == "" {
+		*file, _ = lookupEnv(EnvFile)
+	}
+	if *file != "" {
+		err = loadFile(fields, *file)
+		if err != nil {
+			return
+		}
+	}
+
+	// env
+	for i := range fields {
+		f := &fields[i]
+		if f.env == "" {
+			continue
+		}
+		s, ok := lookupEnv(f.env)
+		if !ok || s == "" {
+			continue
+		}
+		err = set(f.value, s)
+		if err != nil {
+			err = fmt.Errorf("%w. env %s: %v", ErrConfigInvalid, f.env, err)
+			return
+		}
+	}
+
+	// flags
+	for _, sf := range setFlags {
+		err = set(sf.f.value, sf.value)
+		if err != nil {
+			err = fmt.Errorf("%w. flag -%s: %v", ErrConfigInvalid, sf.f.flag, err)
+			return
+		}
+	}
+
+	// validation
+	if v, ok := cfg.(Validator); ok {
+		err = v.Validate()
+		if err != nil {
+			err = fmt.Errorf("%w. %v", ErrConfigInvalid, err)
+			return
+		}
+	}
+
+	return
+}
+
+// Print writes the effective configuration, one key = value per line, with the secret values redacted
+func Print(w io.Writer, cfg any) (err error) {
+	fields, err := fieldsOf(cfg)
+	if err != nil {
+		return
+	}
+
+	for _, f := range fields {
+		value := format(f.value)
+		if f.secret && value != "" {
+			value = redacted
+		}
+		_, err = fmt.Fprintf(w, "%s = %s\n", f.key, value)
+		if err != nil {
+			err = fmt.Errorf("%w. %v", ErrConfigInternal, err)
+			return
+		}
+	}
+	return
+}
+
+// fieldsOf returns the configurable fields of cfg, allocating its nil sections
+func fieldsOf(cfg any) (fields []field, err error) {
+	rv := reflect.ValueOf(cfg)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		err = fmt.Errorf("%w. config must be a pointer to a struct, got %T", ErrConfigInternal, cfg)
+		return
+	}
+
+	err = walk(rv.Elem(), "", &fields)
+	return
+}
+
+// walk appends the configurable fields of the struct sv to fields, prefixing their keys
+func walk(sv reflect.Value, prefix string, fields *[]field) (err error) {
+	st := sv.Type()
+	for i := 0; i < st.NumField(); i++ {
+		sf := st.Field(i)
+		value := sv.Field(i)
+		tag, ok := sf.Tag.Lookup("config")
+		if (!sf.Anonymous && (!sf.IsExported() || !ok)) || tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		// -> sections
+		if value.Kind() == reflect.Pointer && value.Type().Elem().Kind() == reflect.Struct {
+			if value.IsNil() {
+				value.Set(reflect.New(value.Type().Elem()))
+			}
+			value = value.Elem()
+		}
+		if value.Kind() == reflect.Struct {
+			section := prefix
+			if !sf.Anonymous || name != "" {
+				section = prefix + name + "."
+			}
+			err = walk(value, section, fields)
+			if err != nil {
+				return
+			}
+			continue
+		}
+
+		// -> values
+		if !supported(value.Type()) {
+			err = fmt.Errorf("%w. field %s has unsupported type %s", ErrConfigInternal, sf.Name, value.Type())
+			return
+		}
+		*fields = append(*fields, field{
+			key:	prefix + name,
+			env:	sf.Tag.Get("env"),
+			flag:	sf.Tag.Get("flag"),
+			usage:	sf.Tag.Get("usage"),
+			secret:	options == "secret",
+			value:	value,
+		})
+	}
+	return
+}
+
+// loadFile sets the fields with the values of the file
+func loadFile(fields []field, path string) (err error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		err = fmt.Errorf("%w. %v", ErrConfigInvalid, err)
+		return
+	}
+
+	// deserialization
+	var doc map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &doc)
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(content))
+		dec.UseNumber()
+		err = dec.Decode(&doc)
+	default:
+		err = fmt.Errorf("unknown format %q, use .yaml, .yml or .json", filepath.Ext(path))
+	}
+	if err != nil {
+		err = fmt.Errorf("%w. file %s: %v", ErrConfigInvalid, path, err)
+		return
+	}
+
+	// values
+	values := make(map[string]string)
+	flatten(doc, "", values)
+	byKey := make(map[string]*field, len(fields))
+	for i := range fields {
+		byKey[fields[i].key] = &fields[i]
+	}
+	for key, s := range values {
+		f, ok := byKey[key]
+		if !ok {
+			err = fmt.Errorf("%w. file %s: unknown key %s", ErrConfigInvalid, path, key)
+			return
+		}
+		err = set(f.value, s)
+		if err != nil {
+			err = fmt.Errorf("%w. file %s: %s: %v", ErrConfigInvalid, path, key, err)
+			return
+		}
+	}
+	return
+}
+
+// flatten sets values with the scalar values of doc by dotted key, lists are joined by commas
+func flatten(doc map[string]any, prefix string, values map[string]string) {
+	for key, v := range doc {
+		switch v := v.(type) {
+		case map[string]any:
+			flatten(v, prefix+key+".", values)
+		case []any:
+			items := make([]string, len(v))
+			for i, item := range v {
+				items[i] = fmt.Sprint(item)
+			}
+			values[prefix+key] = strings.Join(items, ",")
+		case nil:
+			values[prefix+key] = ""
+		default:
+			values[prefix+key] = fmt.Sprint(v)
+		}
+	}
+}
+
+// set parses s and sets it as the value of the field
+func set(value reflect.Value, s string) (err error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case value.Type() == reflect.TypeOf(time.Duration(0)):
+		var d time.Duration
+		d, err = time.ParseDuration(s)
+		if err != nil {
+			return
+		}
+		value.SetInt(int64(d))
+	case value.Kind() == reflect.String:
+		value.SetString(s)
+	case value.Kind() == reflect.Bool:
+		var b bool
+		b, err = strconv.ParseBool(s)
+		if err != nil {
+			return
+		}
+		value.SetBool(b)
+	case value.Kind() == reflect.Int, value.Kind() == reflect.Int64:
+		var i int64
+		i, err = strconv.ParseInt(s, 10, value.Type().Bits())
+		if err != nil {
+			return
+		}
+		value.SetInt(i)
+	case value.Kind() == reflect.Float64:
+		var f float64
+		f, err = strconv.ParseFloat(s, 64)
+		if err != nil {
+			return
+		}
+		value.SetFloat(f)
+	case value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		value.Set(reflect.ValueOf(items).Convert(value.Type()))
+	default:
+		err = fmt.Errorf("%w. unsupported type %s", ErrConfigInternal, value.Type())
+	}
+	return
+}
+
+// supported reports whether fields of type t can be configured
+func supported(t reflect.Type) bool {
+	switch {
+	case t == reflect.TypeOf(time.Duration(0)), t.Kind() == reflect.String, t.Kind() == reflect.Bool,
+		t.Kind() == reflect.Int, t.Kind() == reflect.Int64, t.Kind() == reflect.Float64:
+		return true
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String:
+		return true
+	}
+	return false
+}
+
+// format returns the value of a field as it is written in env vars and flags
+func format(value reflect.Value) string {
+	switch {
+	case value.Type() == reflect.TypeOf(time.Duration(0)):
+		return time.Duration(value.Int()).String()
+	case value.Kind() == reflect.Slice:
+		return strings.Join(value.Convert(reflect.TypeOf([]string(nil))).Interface().([]string), ",")
+	}
+	return fmt.Sprint(value.Interface())
+}
+
+// valueFlag is a flag.Value that records the values of a field given by the command line
+type valueFlag struct {
+	// def is the default value shown in the help
+	def		string
+	// isBool allows the flag without value (-name is -name=true)
+	isBool	bool
+	// set records the value
+	set		func(s string) error
+}
+
+func (v *valueFlag) String() string {
+	if v == nil {
+		return ""
+	}
+	return v.def
+}
+
+func (v *valueFlag) Set(s string) error {
+	return v.set(s)
+}
+
+func (v *valueFlag) IsBoolFlag() bool {
+	return v.isBool
+}
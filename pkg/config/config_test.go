@@ -0,0 +1,158 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// configTest is a configuration with every supported type
+type configTest struct {
+	Name	string			`config:"name" env:"NAME" flag:"name"`
+	Secret	string			`config:"secret,secret" env:"SECRET"`
+	DB		*configTestDB	`config:"db"`
+	Tags	[]string		`config:"tags" env:"TAGS" flag:"tags"`
+	Ignored	string
+}
+
+type configTestDB struct {
+	configTestConn
+	Timeout	time.Duration	`config:"timeout" env:"DB_TIMEOUT" flag:"db-timeout"`
+	Retries	int				`config:"retries" env:"DB_RETRIES"`
+	Ratio	float64			`config:"ratio"`
+	Migrate	bool			`config:"migrate" flag:"db-migrate"`
+}
+
+type configTestConn struct {
+	Addr	string	`config:"addr" env:"DB_ADDR"`
+}
+
+func (c *configTest) Validate() (err error) {
+	if c.Name == "invalid" {
+		err = errors.New("name is invalid")
+	}
+	return
+}
+
+// writeFile writes a config file in a temporary dir and returns its path
+func writeFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+// Tests for Load function
+func TestLoad(t *testing.T) {
+	type input struct { args []string; env map[string]string; file string; fileName string }
+	type output struct { cfg *configTest; rest []string; err error }
+	type testCase struct {
+		name string
+		input input
+		output output
+	}
+
+	cases := []testCase{
+		// valid cases
+		{
+			name: "defaults",
+			input: input{},
+			output: output{cfg: &configTest{Name: "default", DB: &configTestDB{Timeout: time.Second}}},
+		},
+		{
+			name: "yaml file",
+			input: input{fileName: "cfg.yaml", file: "name: file\ntags: [a, b]\ndb:\n  addr: db:3306\n  timeout: 5s\n  retries: 3\n  ratio: 0.5\n  migrate: true\n"},
+			output: output{cfg: &configTest{Name: "file", Tags: []string{"a", "b"}, DB: &configTestDB{configTestConn: configTestConn{Addr: "db:3306"}, Timeout: 5 * time.Second, Retries: 3, Ratio: 0.5, Migrate: true}}},
+		},
+		{
+			name: "json file",
+			input: input{fileName: "cfg.json", file: `{"name": "file", "db": {"retries": 3, "timeout": "1m"}}`},
+			output: output{cfg: &configTest{Name: "file", DB: &configTestDB{Timeout: time.Minute, Retries: 3}}},
+		},
+		{
+			name: "env overrides file",
+			input: input{fileName: "cfg.yaml", file: "name: file\ndb:\n  retries: 3\n", env: map[string]string{"NAME": "env", "DB_RETRIES": "", "TAGS": "a, ,b", "DB_ADDR": "env:3306"}},
+			output: output{cfg: &configTest{Name: "env", Tags: []string{"a", "b"}, DB: &configTestDB{configTestConn: configTestConn{Addr: "env:3306"}, Timeout: time.Second, Retries: 3}}},
+		},
+		{
+			name: "flags override env",
+			input: input{args: []string{"-name", "flag", "-db-timeout=2s", "-db-migrate", "up", "1"}, env: map[string]string{"NAME": "env", "DB_TIMEOUT": "3s"}},
+			output: output{cfg: &configTest{Name: "flag", DB: &configTestDB{Timeout: 2 * time.Second, Migrate: true}}, rest: []string{"up", "1"}},
+		},
+
+		// invalid cases
+		{name: "invalid env", input: input{env: map[string]string{"DB_RETRIES": "three"}}, output: output{err: ErrConfigInvalid}},
+		{name: "invalid duration", input: input{env: map[string]string{"DB_TIMEOUT": "5"}}, output: output{err: ErrConfigInvalid}},
+		{name: "invalid flag", input: input{args: []string{"-db-timeout", "soon"}}, output: output{err: ErrConfigInvalid}},
+		{name: "unknown flag", input: input{args: []string{"-color", "red"}}, output: output{err: ErrConfigInvalid}},
+		{name: "unknown key", input: input{fileName: "cfg.yaml", file: "db:\n  color: red\n"}, output: output{err: ErrConfigInvalid}},
+		{name: "unknown format", input: input{fileName: "cfg.toml", file: "name = 'file'"}, output: output{err: ErrConfigInvalid}},
+		{name: "invalid", input: input{env: map[string]string{"NAME": "invalid"}}, output: output{err: ErrConfigInvalid}},
+		{name: "help", input: input{args: []string{"-h"}}, output: output{err: flag.ErrHelp}},
+	}
+
+	// run tests
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// arrange
+			cfg := &configTest{Name: "default", DB: &configTestDB{Timeout: time.Second}}
+			env := c.input.env
+			if c.input.file != "" {
+				env = map[string]string{EnvFile: writeFile(t, c.input.fileName, c.input.file)}
+				for k, v := range c.input.env {
+					env[k] = v
+				}
+			}
+			lookupEnv := func(key string) (value string, ok bool) { value, ok = env[key]; return }
+
+			// act
+			rest, err := Load(cfg, c.input.args, lookupEnv)
+
+			// assert
+			require.ErrorIs(t, err, c.output.err)
+			if c.output.err == nil {
+				require.Equal(t, c.output.cfg, cfg)
+				require.Equal(t, c.output.rest, rest)
+			}
+		})
+	}
+
+	t.Run("flag names the file", func(t *testing.T) {
+		// arrange
+		cfg := &configTest{}
+		path := writeFile(t, "cfg.yml", "name: file\n")
+
+		// act
+		_, err := Load(cfg, []string{"-config", path}, func(key string) (string, bool) { return "", false })
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, "file", cfg.Name)
+	})
+}
+
+// Tests for Print function
+func TestPrint(t *testing.T) {
+	// arrange
+	cfg := &configTest{Name: "app", Secret: "password", Tags: []string{"a", "b"}, DB: &configTestDB{Timeout: time.Second}}
+	var b strings.Builder
+
+	// act
+	err := Print(&b, cfg)
+
+	// assert
+	require.NoError(t, err)
+	require.Equal(t, "name = app\n"+
+		"secret = [redacted]\n"+
+		"db.addr = \n"+
+		"db.timeout = 1s\n"+
+		"db.retries = 0\n"+
+		"db.ratio = 0\n"+
+		"db.migrate = false\n"+
+		"tags = a,b\n", b.String())
+}
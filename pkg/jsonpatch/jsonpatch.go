@@ -0,0 +1,432 @@
+// Package jsonpatch applies RFC 7396 JSON Merge Patch and RFC 6902 JSON Patch documents to json documents
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// media types of the patch documents
+const (
+	// ContentTypeMergePatch is the media type of RFC 7396 JSON Merge Patch documents
+	ContentTypeMergePatch = "application/merge-patch+json"
+	// ContentTypeJSONPatch is the media type of RFC 6902 JSON Patch documents
+	ContentTypeJSONPatch = "application/json-patch+json"
+)
+
+var (
+	// ErrPatchInvalid is returned when the patch (or the document) is malformed
+	ErrPatchInvalid = errors.New("patch invalid")
+	// ErrPatchConflict is returned when the patch can not be applied to the document (missing path or failed test)
+	ErrPatchConflict = errors.New("patch conflict")
+)
+
+// MergePatch applies an RFC 7396 JSON Merge Patch to doc and returns the patched document
+// - null members of the patch remove the member from the document
+func MergePatch(doc []byte, patch []byte) (patched []byte, err error) {
+	// decode
+	var target, p any
+	if err = decode(doc, &target); err != nil {
+		return
+	}
+	if err = decode(patch, &p); err != nil {
+		return
+	}
+
+	// patch
+	target = mergePatch(target, p)
+
+	// encode
+	patched, err = json.Marshal(target)
+	if err != nil {
+		err = fmt.Errorf("%w. %v", ErrPatchInvalid, err)
+	}
+	return
+}
+
+// mergePatch is the MergePatch algorithm of RFC 7396 section 2
+func mergePatch(target any, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = make(map[string]any)
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergePatch(t[k], v)
+	}
+	return t
+}
+
+// operation is an RFC 6902 JSON Patch operation
+type operation struct {
+	Op    string          `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Apply applies an RFC 6902 JSON Patch to doc and returns the patched document
+// - operations are applied in order, if any of them fails the document is not patched
+func Apply(doc []byte, patch []byte) (patched []byte, err error) {
+	// decode
+	var target any
+	if err = decode(doc, &target); err != nil {
+		return
+	}
+	var ops []operation
+	if err = json.Unmarshal(patch, &ops); err != nil {
+		err = fmt.Errorf("%w. %v", ErrPatchInvalid, err)
+		return
+	}
+
+	// patch
+	for i, op := range ops {
+		target, err = apply(target, op)
+		if err != nil {
+			err = fmt.Errorf("%w. operation %d (%s)", err, i, op.Op)
+			return
+		}
+	}
+
+	// encode
+	patched, err = json.Marshal(target)
+	if err != nil {
+		err = fmt.Errorf("%w. %v", ErrPatchInvalid, err)
+	}
+	return
+}
+
+// apply applies one operation to doc and returns the patched document
+func apply(doc any, op operation) (patched any, err error) {
+	// path
+	if op.Path == nil {
+		err = fmt.Errorf("%w. missing path", ErrPatchInvalid)
+		return
+	}
+	path, err := parsePointer(*op.Path)
+	if err != nil {
+		return
+	}
+
+	// value
+	var value any
+	switch op.Op {
+	case "add", "replace", "test":
+		if len(op.Value) == 0 {
+			err = fmt.Errorf("%w. missing value", ErrPatchInvalid)
+			return
+		}
+		if err = decode(op.Value, &value); err != nil {
+			return
+		}
+	}
+
+	// from
+	var from []string
+	switch op.Op {
+	case "move", "copy":
+		if op.From == nil {
+			err = fmt.Errorf("%w. missing from", ErrPatchInvalid)
+			return
+		}
+		if from, err = parsePointer(*op.From); err != nil {
+			return
+		}
+	}
+
+	switch op.Op {
+	case "add":
+		patched, err = add(doc, path, value)
+	case "remove":
+		patched, _, err = remove(doc, path)
+	case "replace":
+		// the root pointer replaces the whole document, which remove rejects
+		if len(path) == 0 {
+			patched = value
+			return
+		}
+		if patched, _, err = remove(doc, path); err != nil {
+			return
+		}
+		patched, err = add(patched, path, value)
+	case "move":
+		if isPrefix(from, path) && len(from) < len(path) {
+			err = fmt.Errorf("%w. can not move a value into one of its children", ErrPatchInvalid)
+			return
+		}
+		var moved any
+		if patched, moved, err = remove(doc, from); err != nil {
+			return
+		}
+		patched, err = add(patched, path, moved)
+	case "copy":
+		var copied any
+		if copied, err = get(doc, from); err != nil {
+			return
+		}
+		patched, err = add(doc, path, deepCopy(copied))
+	case "test":
+		var actual any
+		if actual, err = get(doc, path); err != nil {
+			return
+		}
+		if !equal(actual, value) {
+			err = fmt.Errorf("%w. test failed at %q", ErrPatchConflict, *op.Path)
+			return
+		}
+		patched = doc
+	default:
+		err = fmt.Errorf("%w. unknown op %q", ErrPatchInvalid, op.Op)
+	}
+	return
+}
+
+// parsePointer parses an RFC 6901 JSON Pointer into its reference tokens
+func parsePointer(s string) (tokens []string, err error) {
+	if s == "" {
+		return
+	}
+	if !strings.HasPrefix(s, "/") {
+		err = fmt.Errorf("%w. pointer %q must start with /", ErrPatchInvalid, s)
+		return
+	}
+
+	unescape := strings.NewReplacer("~1", "/", "~0", "~")
+	for _, t := range strings.Split(s[1:], "/") {
+		tokens = append(tokens, unescape.Replace(t))
+	}
+	return
+}
+
+// get returns the value of doc at path
+func get(doc any, path []string) (value any, err error) {
+	value = doc
+	for _, t := range path {
+		switch node := value.(type) {
+		case map[string]any:
+			var ok bool
+			if value, ok = node[t]; !ok {
+				err = fmt.Errorf("%w. member %q not found", ErrPatchConflict, t)
+				return
+			}
+		case []any:
+			var i int
+			if i, err = index(t, len(node)-1); err != nil {
+				return
+			}
+			value = node[i]
+		default:
+			err = fmt.Errorf("%w. %q is not a container", ErrPatchConflict, t)
+			return
+		}
+	}
+	return
+}
+
+// add adds value to doc at path and returns the patched document
+// - arrays insert the value at the index ("-" appends), objects set the member
+func add(doc any, path []string, value any) (patched any, err error) {
+	if len(path) == 0 {
+		patched = value
+		return
+	}
+
+	t := path[0]
+	switch node := doc.(type) {
+	case map[string]any:
+		if len(path) == 1 {
+			node[t] = value
+			patched = node
+			return
+		}
+		child, ok := node[t]
+		if !ok {
+			err = fmt.Errorf("%w. member %q not found", ErrPatchConflict, t)
+			return
+		}
+		if node[t], err = add(child, path[1:], value); err != nil {
+			return
+		}
+		patched = node
+	case []any:
+		if len(path) == 1 {
+			i := len(node)
+			if t != "-" {
+				if i, err = index(t, len(node)); err != nil {
+					return
+				}
+			}
+			node = append(node, nil)
+			copy(node[i+1:], node[i:])
+			node[i] = value
+			patched = node
+			return
+		}
+		var i int
+		if i, err = index(t, len(node)-1); err != nil {
+			return
+		}
+		if node[i], err = add(node[i], path[1:], value); err != nil {
+			return
+		}
+		patched = node
+	default:
+		err = fmt.Errorf("%w. %q is not a container", ErrPatchConflict, t)
+	}
+	return
+}
+
+// remove removes the value of doc at path and returns the patched document and the removed value
+func remove(doc any, path []string) (patched any, removed any, err error) {
+	if len(path) == 0 {
+		err = fmt.Errorf("%w. can not remove the whole document", ErrPatchInvalid)
+		return
+	}
+
+	t := path[0]
+	switch node := doc.(type) {
+	case map[string]any:
+		child, ok := node[t]
+		if !ok {
+			err = fmt.Errorf("%w. member %q not found", ErrPatchConflict, t)
+			return
+		}
+		if len(path) == 1 {
+			delete(node, t)
+			patched, removed = node, child
+			return
+		}
+		if node[t], removed, err = remove(child, path[1:]); err != nil {
+			return
+		}
+		patched = node
+	case []any:
+		var i int
+		if i, err = index(t, len(node)-1); err != nil {
+			return
+		}
+		if len(path) == 1 {
+			removed = node[i]
+			patched = append(node[:i], node[i+1:]...)
+			return
+		}
+		if node[i], removed, err = remove(node[i], path[1:]); err != nil {
+			return
+		}
+		patched = node
+	default:
+		err = fmt.Errorf("%w. %q is not a container", ErrPatchConflict, t)
+	}
+	return
+}
+
+// index parses an array index token, valid indexes are in the range [0, upper]
+func index(t string, upper int) (i int, err error) {
+	if t == "" || (len(t) > 1 && t[0] == '0') || strings.TrimLeft(t, "0123456789") != "" {
+		err = fmt.Errorf("%w. invalid array index %q", ErrPatchInvalid, t)
+		return
+	}
+	i, err = strconv.Atoi(t)
+	if err != nil || i > upper {
+		err = fmt.Errorf("%w. array index %q out of bounds", ErrPatchConflict, t)
+		return
+	}
+	return
+}
+
+// isPrefix reports whether prefix is a prefix of path
+func isPrefix(prefix []string, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// equal reports whether the json values a and b are equal (numbers are compared by value)
+func equal(a any, b any) bool {
+	switch va := a.(type) {
+	case map[string]any:
+		vb, ok := b.(map[string]any)
+		if !ok || len(va) != len(vb) {
+			return false
+		}
+		for k := range va {
+			if _, ok := vb[k]; !ok || !equal(va[k], vb[k]) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		vb, ok := b.([]any)
+		if !ok || len(va) != len(vb) {
+			return false
+		}
+		for i := range va {
+			if !equal(va[i], vb[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		vb, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		fa, errA := va.Float64()
+		fb, errB := vb.Float64()
+		return errA == nil && errB == nil && fa == fb
+	default:
+		return a == b
+	}
+}
+
+// deepCopy returns a deep copy of the json value v
+func deepCopy(v any) any {
+	switch node := v.(type) {
+	case map[string]any:
+		cp := make(map[string]any, len(node))
+		for k, child := range node {
+			cp[k] = deepCopy(child)
+		}
+		return cp
+	case []any:
+		cp := make([]any, len(node))
+		for i, child := range node {
+			cp[i] = deepCopy(child)
+		}
+		return cp
+	default:
+		return v
+	}
+}
+
+// decode decodes the json value b into ptr keeping numbers as json.Number
+func decode(b []byte, ptr *any) (err error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err = dec.Decode(ptr); err != nil {
+		err = fmt.Errorf("%w. %v", ErrPatchInvalid, err)
+		return
+	}
+	if dec.More() {
+		err = fmt.Errorf("%w. trailing data after json value", ErrPatchInvalid)
+	}
+	return
+}
@@ -0,0 +1,103 @@
+package jsonpatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Tests for MergePatch function (examples from RFC 7396 appendix A)
+func TestMergePatch(t *testing.T) {
+	type input struct { doc string; patch string }
+	type output struct { patched string; err error }
+	type testCase struct {
+		name string
+		input input
+		output output
+	}
+
+	cases := []testCase{
+		// valid cases
+		{name: "replace member", input: input{doc: `{"a":"b"}`, patch: `{"a":"c"}`}, output: output{patched: `{"a":"c"}`}},
+		{name: "add member", input: input{doc: `{"a":"b"}`, patch: `{"b":"c"}`}, output: output{patched: `{"a":"b","b":"c"}`}},
+		{name: "null removes member", input: input{doc: `{"a":"b"}`, patch: `{"a":null}`}, output: output{patched: `{}`}},
+		{name: "null removes nested member", input: input{doc: `{"a":{"b":"c"}}`, patch: `{"a":{"b":"d","c":null}}`}, output: output{patched: `{"a":{"b":"d"}}`}},
+		{name: "arrays are replaced", input: input{doc: `{"a":[{"b":"c"}]}`, patch: `{"a":[1]}`}, output: output{patched: `{"a":[1]}`}},
+		{name: "non object patch replaces document", input: input{doc: `{"a":"foo"}`, patch: `"bar"`}, output: output{patched: `"bar"`}},
+		{name: "zero values are kept", input: input{doc: `{"count":3,"price":1.5}`, patch: `{"count":0,"price":0}`}, output: output{patched: `{"count":0,"price":0}`}},
+
+		// invalid cases
+		{name: "invalid patch", input: input{doc: `{"a":"b"}`, patch: `{"a":`}, output: output{err: ErrPatchInvalid}},
+	}
+
+	// run tests
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// act
+			patched, err := MergePatch([]byte(c.input.doc), []byte(c.input.patch))
+
+			// assert
+			if c.output.err != nil {
+				require.ErrorIs(t, err, c.output.err)
+				return
+			}
+			require.NoError(t, err)
+			require.JSONEq(t, c.output.patched, string(patched))
+		})
+	}
+}
+
+// Tests for Apply function (examples from RFC 6902 appendix A)
+func TestApply(t *testing.T) {
+	type input struct { doc string; patch string }
+	type output struct { patched string; err error }
+	type testCase struct {
+		name string
+		input input
+		output output
+	}
+
+	cases := []testCase{
+		// valid cases
+		{name: "add object member", input: input{doc: `{"foo":"bar"}`, patch: `[{"op":"add","path":"/baz","value":"qux"}]`}, output: output{patched: `{"baz":"qux","foo":"bar"}`}},
+		{name: "add array element", input: input{doc: `{"foo":["bar","baz"]}`, patch: `[{"op":"add","path":"/foo/1","value":"qux"}]`}, output: output{patched: `{"foo":["bar","qux","baz"]}`}},
+		{name: "add to the end of an array", input: input{doc: `{"foo":["bar"]}`, patch: `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`}, output: output{patched: `{"foo":["bar",["abc","def"]]}`}},
+		{name: "remove object member", input: input{doc: `{"baz":"qux","foo":"bar"}`, patch: `[{"op":"remove","path":"/baz"}]`}, output: output{patched: `{"foo":"bar"}`}},
+		{name: "remove array element", input: input{doc: `{"foo":["bar","qux","baz"]}`, patch: `[{"op":"remove","path":"/foo/1"}]`}, output: output{patched: `{"foo":["bar","baz"]}`}},
+		{name: "replace value", input: input{doc: `{"baz":"qux","foo":"bar"}`, patch: `[{"op":"replace","path":"/baz","value":"boo"}]`}, output: output{patched: `{"baz":"boo","foo":"bar"}`}},
+		{name: "replace with null", input: input{doc: `{"type":"fruit"}`, patch: `[{"op":"replace","path":"/type","value":null}]`}, output: output{patched: `{"type":null}`}},
+		{name: "replace whole document", input: input{doc: `{"foo":"bar"}`, patch: `[{"op":"replace","path":"","value":{"baz":"qux"}}]`}, output: output{patched: `{"baz":"qux"}`}},
+		{name: "move value", input: input{doc: `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, patch: `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`}, output: output{patched: `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`}},
+		{name: "move array element", input: input{doc: `{"foo":["all","grass","cows","eat"]}`, patch: `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`}, output: output{patched: `{"foo":["all","cows","eat","grass"]}`}},
+		{name: "copy value", input: input{doc: `{"foo":{"bar":1}}`, patch: `[{"op":"copy","from":"/foo","path":"/baz"}]`}, output: output{patched: `{"foo":{"bar":1},"baz":{"bar":1}}`}},
+		{name: "test success", input: input{doc: `{"baz":"qux","foo":["a",2,"c"]}`, patch: `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2.0}]`}, output: output{patched: `{"baz":"qux","foo":["a",2,"c"]}`}},
+		{name: "escaped pointer", input: input{doc: `{"/":9,"~1":10}`, patch: `[{"op":"test","path":"/~01","value":10},{"op":"remove","path":"/~1"}]`}, output: output{patched: `{"~1":10}`}},
+
+		// invalid cases
+		{name: "test failure", input: input{doc: `{"baz":"qux"}`, patch: `[{"op":"test","path":"/baz","value":"bar"}]`}, output: output{err: ErrPatchConflict}},
+		{name: "add to nonexistent target", input: input{doc: `{"foo":"bar"}`, patch: `[{"op":"add","path":"/baz/bat","value":"qux"}]`}, output: output{err: ErrPatchConflict}},
+		{name: "remove nonexistent member", input: input{doc: `{"foo":"bar"}`, patch: `[{"op":"remove","path":"/baz"}]`}, output: output{err: ErrPatchConflict}},
+		{name: "array index out of bounds", input: input{doc: `{"foo":["bar"]}`, patch: `[{"op":"add","path":"/foo/2","value":"baz"}]`}, output: output{err: ErrPatchConflict}},
+		{name: "array index with leading zero", input: input{doc: `{"foo":["bar","baz"]}`, patch: `[{"op":"remove","path":"/foo/01"}]`}, output: output{err: ErrPatchInvalid}},
+		{name: "unknown op", input: input{doc: `{"foo":"bar"}`, patch: `[{"op":"merge","path":"/foo","value":"baz"}]`}, output: output{err: ErrPatchInvalid}},
+		{name: "missing value", input: input{doc: `{"foo":"bar"}`, patch: `[{"op":"add","path":"/baz"}]`}, output: output{err: ErrPatchInvalid}},
+		{name: "move into a child", input: input{doc: `{"foo":{"bar":1}}`, patch: `[{"op":"move","from":"/foo","path":"/foo/bar"}]`}, output: output{err: ErrPatchInvalid}},
+		{name: "patch is not an array", input: input{doc: `{"foo":"bar"}`, patch: `{"op":"remove","path":"/foo"}`}, output: output{err: ErrPatchInvalid}},
+	}
+
+	// run tests
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// act
+			patched, err := Apply([]byte(c.input.doc), []byte(c.input.patch))
+
+			// assert
+			if c.output.err != nil {
+				require.ErrorIs(t, err, c.output.err)
+				return
+			}
+			require.NoError(t, err)
+			require.JSONEq(t, c.output.patched, string(patched))
+		})
+	}
+}
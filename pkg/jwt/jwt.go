@@ -0,0 +1,310 @@
+// Package jwt verifies and signs JSON Web Tokens (RFC 7519) in compact serialization
+// - the supported algorithms are HS256 (shared secret) and RS256 (RSA key pair), "none" is never accepted
+// - a token is only accepted with the algorithm of the key configured for it, so an RS256 public key
+//   can not be used as an HS256 secret
+// - tokens must have an expiration time (exp), so a leaked token does not grant access forever
+package jwt
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrTokenMalformed is returned when the token is not a JWT
+	ErrTokenMalformed = errors.New("token malformed")
+	// ErrTokenAlgorithm is returned when the algorithm of the token has no key configured
+	ErrTokenAlgorithm = errors.New("token algorithm not accepted")
+	// ErrTokenSignature is returned when the signature does not match
+	ErrTokenSignature = errors.New("token signature invalid")
+	// ErrTokenExpired is returned when the token expired
+	ErrTokenExpired = errors.New("token expired")
+	// ErrTokenNotValidYet is returned when the token is used before its not before time
+	ErrTokenNotValidYet = errors.New("token not valid yet")
+	// ErrTokenClaims is returned when a claim is missing or does not match the expected value
+	ErrTokenClaims = errors.New("token claims invalid")
+)
+
+// algorithms
+const (
+	// AlgHS256 is HMAC with SHA-256
+	AlgHS256 = "HS256"
+	// AlgRS256 is RSASSA-PKCS1-v1_5 with SHA-256
+	AlgRS256 = "RS256"
+)
+
+// Audience is the aud claim, a string or an array of strings in the token
+type Audience []string
+
+func (a *Audience) UnmarshalJSON(b []byte) (err error) {
+	var s string
+	if err = json.Unmarshal(b, &s); err == nil {
+		*a = Audience{s}
+		return
+	}
+
+	var items []string
+	err = json.Unmarshal(b, &items)
+	if err != nil {
+		return
+	}
+	*a = Audience(items)
+	return
+}
+
+// Claims are the registered claims of a token
+// - times are seconds since the unix epoch, 0 if the claim is missing
+type Claims struct {
+	Subject		string		`json:"sub,omitempty"`
+	Issuer		string		`json:"iss,omitempty"`
+	Audience	Audience	`json:"aud,omitempty"`
+	ExpiresAt	int64		`json:"exp,omitempty"`
+	NotBefore	int64		`json:"nbf,omitempty"`
+	IssuedAt	int64		`json:"iat,omitempty"`
+}
+
+// header is the JOSE header of a token
+type header struct {
+	Alg	string	`json:"alg"`
+	Typ	string	`json:"typ,omitempty"`
+}
+
+// ConfigVerifier is the configuration of Verifier
+type ConfigVerifier struct {
+	// SecretHS256 verifies the HS256 tokens (nil rejects them)
+	SecretHS256 []byte
+	// PublicKeyRS256 verifies the RS256 tokens (nil rejects them)
+	PublicKeyRS256 *rsa.PublicKey
+	// Issuer must be the iss claim of the tokens ("" accepts any issuer)
+	Issuer string
+	// Audience must be one of the aud claim of the tokens ("" accepts any audience)
+	Audience string
+	// Leeway is the clock skew tolerated when checking exp and nbf
+	Leeway time.Duration
+	// Now returns the current time (time.Now by default)
+	Now func() time.Time
+}
+
+// NewVerifier returns new Verifier
+// - cfg can be nil or have zero values, in that case defaults are used (and every token is rejected)
+func NewVerifier(cfg *ConfigVerifier) *Verifier {
+	// default config
+	defaultCfg := ConfigVerifier{
+		Now: time.Now,
+	}
+	if cfg != nil {
+		defaultCfg.SecretHS256 = cfg.SecretHS256
+		defaultCfg.PublicKeyRS256 = cfg.PublicKeyRS256
+		defaultCfg.Issuer = cfg.Issuer
+		defaultCfg.Audience = cfg.Audience
+		if cfg.Leeway > 0 {
+			defaultCfg.Leeway = cfg.Leeway
+		}
+		if cfg.Now != nil {
+			defaultCfg.Now = cfg.Now
+		}
+	}
+
+	return &Verifier{cfg: defaultCfg}
+}
+
+// Verifier verifies the signature and claims of tokens
+type Verifier struct {
+	// cfg is the configuration
+	cfg ConfigVerifier
+}
+
+// Verify returns the claims of a token once its signature and claims are verified
+// - ErrTokenMalformed, ErrTokenAlgorithm, ErrTokenSignature, ErrTokenExpired, ErrTokenNotValidYet or ErrTokenClaims
+func (v *Verifier) Verify(token string) (claims *Claims, err error) {
+	// parts
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		err = fmt.Errorf("%w. expected 3 parts, got %d", ErrTokenMalformed, len(parts))
+		return
+	}
+	var h header
+	err = decodePart(parts[0], &h)
+	if err != nil {
+		return
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		err = fmt.Errorf("%w. signature: %v", ErrTokenMalformed, err)
+		return
+	}
+
+	// signature
+	signed := []byte(parts[0] + "." + parts[1])
+	switch {
+	case h.Alg == AlgHS256 && v.cfg.SecretHS256 != nil:
+		if !hmac.Equal(signature, signHMAC(signed, v.cfg.SecretHS256)) {
+			err = ErrTokenSignature
+			return
+		}
+	case h.Alg == AlgRS256 && v.cfg.PublicKeyRS256 != nil:
+		digest := sha256.Sum256(signed)
+		if errVerify := rsa.VerifyPKCS1v15(v.cfg.PublicKeyRS256, crypto.SHA256, digest[:], signature); errVerify != nil {
+			err = fmt.Errorf("%w. %v", ErrTokenSignature, errVerify)
+			return
+		}
+	default:
+		err = fmt.Errorf("%w. %q", ErrTokenAlgorithm, h.Alg)
+		return
+	}
+
+	// claims
+	claims = new(Claims)
+	err = decodePart(parts[1], claims)
+	if err != nil {
+		claims = nil
+		return
+	}
+	err = v.validate(claims)
+	if err != nil {
+		claims = nil
+		return
+	}
+	return
+}
+
+// validate checks the time and the expected claims
+func (v *Verifier) validate(claims *Claims) (err error) {
+	now := v.cfg.Now()
+
+	// -> times
+	if claims.ExpiresAt == 0 {
+		err = fmt.Errorf("%w. exp is required", ErrTokenClaims)
+		return
+	}
+	if !now.Before(time.Unix(claims.ExpiresAt, 0).Add(v.cfg.Leeway)) {
+		err = ErrTokenExpired
+		return
+	}
+	if claims.NotBefore != 0 && now.Before(time.Unix(claims.NotBefore, 0).Add(-v.cfg.Leeway)) {
+		err = ErrTokenNotValidYet
+		return
+	}
+
+	// -> expected values
+	if v.cfg.Issuer != "" && claims.Issuer != v.cfg.Issuer {
+		err = fmt.Errorf("%w. iss %q is not %q", ErrTokenClaims, claims.Issuer, v.cfg.Issuer)
+		return
+	}
+	if v.cfg.Audience != "" {
+		var ok bool
+		for _, aud := range claims.Audience {
+			ok = ok || aud == v.cfg.Audience
+		}
+		if !ok {
+			err = fmt.Errorf("%w. aud %q does not have %q", ErrTokenClaims, []string(claims.Audience), v.cfg.Audience)
+			return
+		}
+	}
+	return
+}
+
+// decodePart decodes a base64url encoded JSON part of a token into v
+func decodePart(part string, v any) (err error) {
+	b, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		err = fmt.Errorf("%w. %v", ErrTokenMalformed, err)
+		return
+	}
+	err = json.Unmarshal(b, v)
+	if err != nil {
+		err = fmt.Errorf("%w. %v", ErrTokenMalformed, err)
+		return
+	}
+	return
+}
+
+// signHMAC returns the HS256 signature of signed
+func signHMAC(signed []byte, secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(signed)
+	return mac.Sum(nil)
+}
+
+// SignHS256 returns a token with the claims signed with the secret
+func SignHS256(claims *Claims, secret []byte) (token string, err error) {
+	signed, err := encode(AlgHS256, claims)
+	if err != nil {
+		return
+	}
+
+	token = signed + "." + base64.RawURLEncoding.EncodeToString(signHMAC([]byte(signed), secret))
+	return
+}
+
+// SignRS256 returns a token with the claims signed with the private key
+func SignRS256(claims *Claims, key *rsa.PrivateKey) (token string, err error) {
+	signed, err := encode(AlgRS256, claims)
+	if err != nil {
+		return
+	}
+
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return
+	}
+	token = signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+	return
+}
+
+// encode returns the header and claims parts of a token
+func encode(alg string, claims *Claims) (signed string, err error) {
+	h, err := json.Marshal(header{Alg: alg, Typ: "JWT"})
+	if err != nil {
+		return
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return
+	}
+
+	signed = base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	return
+}
+
+// ParseRSAPublicKeyPEM returns the RSA public key of a PEM block
+// - PUBLIC KEY (PKIX, as written by openssl rsa -pubout) or RSA PUBLIC KEY (PKCS #1)
+func ParseRSAPublicKeyPEM(b []byte) (key *rsa.PublicKey, err error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		err = errors.New("no PEM block found")
+		return
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		var pub any
+		pub, err = x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return
+		}
+		var ok bool
+		key, ok = pub.(*rsa.PublicKey)
+		if !ok {
+			err = fmt.Errorf("public key is %T, not RSA", pub)
+			return
+		}
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		err = fmt.Errorf("unexpected PEM block %q", block.Type)
+	}
+	return
+}
@@ -0,0 +1,138 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Tests for Verifier.Verify method
+func TestVerifier_Verify(t *testing.T) {
+	// keys
+	secret := []byte("secret")
+	keyRSA, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	keyRSAOther, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	now := time.Unix(1700000000, 0)
+	valid := &Claims{Subject: "alice", Issuer: "issuer", Audience: Audience{"api", "other"}, ExpiresAt: now.Unix() + 60}
+	tokenHS256 := func(c *Claims, secret []byte) string {
+		token, err := SignHS256(c, secret)
+		require.NoError(t, err)
+		return token
+	}
+	tokenRS256 := func(c *Claims, key *rsa.PrivateKey) string {
+		token, err := SignRS256(c, key)
+		require.NoError(t, err)
+		return token
+	}
+	// -> raw header and claims, signed with secret
+	tokenRaw := func(header string, claims string) string {
+		signed := base64.RawURLEncoding.EncodeToString([]byte(header)) + "." + base64.RawURLEncoding.EncodeToString([]byte(claims))
+		return signed + "." + base64.RawURLEncoding.EncodeToString(signHMAC([]byte(signed), secret))
+	}
+	// -> HS256 signed with the bytes of the RSA public key, as in the algorithm confusion attack
+	pubPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&keyRSA.PublicKey)})
+
+	type input struct { token string; cfg *ConfigVerifier }
+	type output struct { subject string; err error }
+	type testCase struct {
+		name string
+		input input
+		output output
+	}
+
+	cfgHS256 := &ConfigVerifier{SecretHS256: secret, Issuer: "issuer", Audience: "api", Now: func() time.Time { return now }}
+	cfgRS256 := &ConfigVerifier{PublicKeyRS256: &keyRSA.PublicKey, Now: func() time.Time { return now }}
+	cases := []testCase{
+		// valid cases
+		{name: "HS256", input: input{token: tokenHS256(valid, secret), cfg: cfgHS256}, output: output{subject: "alice"}},
+		{name: "RS256", input: input{token: tokenRS256(valid, keyRSA), cfg: cfgRS256}, output: output{subject: "alice"}},
+		{name: "audience string", input: input{token: tokenRaw(`{"alg":"HS256"}`, `{"sub":"bob","iss":"issuer","aud":"api","exp":1700000060}`), cfg: cfgHS256}, output: output{subject: "bob"}},
+		{name: "expired within leeway", input: input{token: tokenHS256(&Claims{ExpiresAt: now.Unix() - 5}, secret), cfg: &ConfigVerifier{SecretHS256: secret, Leeway: 10 * time.Second, Now: func() time.Time { return now }}}, output: output{}},
+
+		// invalid cases
+		{name: "malformed", input: input{token: "abc.def", cfg: cfgHS256}, output: output{err: ErrTokenMalformed}},
+		{name: "malformed header", input: input{token: "!!!.e30.", cfg: cfgHS256}, output: output{err: ErrTokenMalformed}},
+		{name: "alg none", input: input{token: tokenRaw(`{"alg":"none"}`, `{"sub":"alice","exp":1700000060}`), cfg: cfgHS256}, output: output{err: ErrTokenAlgorithm}},
+		{name: "RS256 without public key", input: input{token: tokenRS256(valid, keyRSA), cfg: cfgHS256}, output: output{err: ErrTokenAlgorithm}},
+		{name: "HS256 signed with the public key", input: input{token: tokenHS256(valid, pubPEM), cfg: cfgRS256}, output: output{err: ErrTokenAlgorithm}},
+		{name: "HS256 wrong secret", input: input{token: tokenHS256(valid, []byte("other")), cfg: cfgHS256}, output: output{err: ErrTokenSignature}},
+		{name: "RS256 wrong key", input: input{token: tokenRS256(valid, keyRSAOther), cfg: cfgRS256}, output: output{err: ErrTokenSignature}},
+		{name: "expired", input: input{token: tokenHS256(&Claims{Issuer: "issuer", Audience: Audience{"api"}, ExpiresAt: now.Unix()}, secret), cfg: cfgHS256}, output: output{err: ErrTokenExpired}},
+		{name: "not valid yet", input: input{token: tokenHS256(&Claims{Issuer: "issuer", Audience: Audience{"api"}, ExpiresAt: now.Unix() + 60, NotBefore: now.Unix() + 30}, secret), cfg: cfgHS256}, output: output{err: ErrTokenNotValidYet}},
+		{name: "without exp", input: input{token: tokenHS256(&Claims{Issuer: "issuer", Audience: Audience{"api"}}, secret), cfg: cfgHS256}, output: output{err: ErrTokenClaims}},
+		{name: "wrong issuer", input: input{token: tokenHS256(&Claims{Issuer: "other", Audience: Audience{"api"}, ExpiresAt: now.Unix() + 60}, secret), cfg: cfgHS256}, output: output{err: ErrTokenClaims}},
+		{name: "wrong audience", input: input{token: tokenHS256(&Claims{Issuer: "issuer", Audience: Audience{"other"}, ExpiresAt: now.Unix() + 60}, secret), cfg: cfgHS256}, output: output{err: ErrTokenClaims}},
+		{name: "no key", input: input{token: tokenHS256(valid, secret), cfg: nil}, output: output{err: ErrTokenAlgorithm}},
+	}
+
+	// run tests
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// arrange
+			v := NewVerifier(c.input.cfg)
+
+			// act
+			claims, err := v.Verify(c.input.token)
+
+			// assert
+			require.ErrorIs(t, err, c.output.err)
+			if c.output.err == nil {
+				require.Equal(t, c.output.subject, claims.Subject)
+			} else {
+				require.Nil(t, claims)
+			}
+		})
+	}
+}
+
+// Tests for ParseRSAPublicKeyPEM function
+func TestParseRSAPublicKeyPEM(t *testing.T) {
+	// arrange
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	pkix, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+
+	type input struct { pem []byte }
+	type output struct { ok bool }
+	type testCase struct {
+		name string
+		input input
+		output output
+	}
+
+	cases := []testCase{
+		// valid cases
+		{name: "PKIX", input: input{pem: pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pkix})}, output: output{ok: true}},
+		{name: "PKCS #1", input: input{pem: pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&key.PublicKey)})}, output: output{ok: true}},
+
+		// invalid cases
+		{name: "not PEM", input: input{pem: []byte("key")}, output: output{ok: false}},
+		{name: "private key", input: input{pem: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})}, output: output{ok: false}},
+	}
+
+	// run tests
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// act
+			pub, err := ParseRSAPublicKeyPEM(c.input.pem)
+
+			// assert
+			if c.output.ok {
+				require.NoError(t, err)
+				require.True(t, key.PublicKey.Equal(pub))
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
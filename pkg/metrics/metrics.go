@@ -0,0 +1,315 @@
+// Package metrics collects counters, histograms and gauges and writes them in the Prometheus text exposition format
+// - metrics are registered once in a Registry, their series are created on first use of their label values
+// - label values must have a bounded number of values (route patterns, not paths)
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the content type of the text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are the upper bounds of the histogram buckets of durations in seconds, from 5ms to 10s
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// metric is a registered metric
+type metric interface {
+	// write writes the metric in the text exposition format
+	write(w *bufio.Writer)
+}
+
+// NewRegistry returns new Registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Registry is a set of metrics exposed together
+type Registry struct {
+	// mu guards metrics
+	mu sync.Mutex
+	// metrics are the registered metrics, in registration order
+	metrics []metric
+	// names are the names of the registered metrics
+	names map[string]bool
+}
+
+// register adds m to the registry
+// - it panics if the name is already registered, metrics are registered on start
+func (r *Registry) register(name string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.names == nil {
+		r.names = make(map[string]bool)
+	}
+	if r.names[name] {
+		panic(fmt.Sprintf("metrics: %s registered twice", name))
+	}
+	r.names[name] = true
+	r.metrics = append(r.metrics, m)
+}
+
+// Counter registers and returns a counter with the label names
+func (r *Registry) Counter(name string, help string, labels ...string) *Counter {
+	c := &Counter{desc: desc{name: name, help: help, typ: "counter", labels: labels}, series: make(map[string]*seriesCounter)}
+	r.register(name, c)
+	return c
+}
+
+// Histogram registers and returns a histogram with the buckets upper bounds (sorted ascending) and the label names
+func (r *Registry) Histogram(name string, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{desc: desc{name: name, help: help, typ: "histogram", labels: labels}, buckets: buckets, series: make(map[string]*seriesHistogram)}
+	r.register(name, h)
+	return h
+}
+
+// GaugeFunc registers a gauge whose value is read from fn when the metrics are written
+func (r *Registry) GaugeFunc(name string, help string, fn func() float64) {
+	r.register(name, &metricFunc{desc: desc{name: name, help: help, typ: "gauge"}, fn: fn})
+}
+
+// CounterFunc registers a counter whose value is read from fn when the metrics are written
+// - fn must never decrease
+func (r *Registry) CounterFunc(name string, help string, fn func() float64) {
+	r.register(name, &metricFunc{desc: desc{name: name, help: help, typ: "counter"}, fn: fn})
+}
+
+// Write writes every metric in the text exposition format
+func (r *Registry) Write(w io.Writer) (err error) {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(bw)
+	}
+	return bw.Flush()
+}
+
+// Handler returns the handler of the metrics endpoint
+func (r *Registry) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		w.WriteHeader(http.StatusOK)
+		r.Write(w)
+	}
+}
+
+// desc describes a metric
+type desc struct {
+	// name is the name of the metric
+	name	string
+	// help is the description of the metric
+	help	string
+	// typ is the type of the metric: counter, gauge or histogram
+	typ		string
+	// labels are the label names of the metric
+	labels	[]string
+}
+
+// writeHeader writes the HELP and TYPE lines of the metric
+func (d *desc) writeHeader(w *bufio.Writer) {
+	help := strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(d.help)
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, help, d.name, d.typ)
+}
+
+// key returns the key of the series of the label values
+// - it panics if the number of values does not match the labels, as it is a programming error
+func (d *desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s has %d labels, got %d values", d.name, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\x00")
+}
+
+// formatLabels returns the labels of a series ({name="value",...}), with an extra label if extra is not empty
+func (d *desc) formatLabels(values []string, extra string, extraValue string) string {
+	if len(values) == 0 && extra == "" {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, label := range d.labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(label)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(values[i]))
+		b.WriteByte('"')
+	}
+	if extra != "" {
+		if len(values) > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(extra)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(extraValue))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// escapeLabel escapes a label value
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// formatFloat formats a sample value
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, +1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// sortedKeys returns the keys of the series, sorted so the output is stable
+func sortedKeys[T any](series map[string]T) []string {
+	keys := make([]string, 0, len(series))
+	for k := range series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Counter is a metric that only increases, by label values
+type Counter struct {
+	desc
+	// mu guards series
+	mu sync.Mutex
+	// series are the series by key of their label values
+	series map[string]*seriesCounter
+}
+
+// seriesCounter is a series of a counter
+type seriesCounter struct {
+	// values are the label values
+	values	[]string
+	// value is the count
+	value	float64
+}
+
+// Inc adds 1 to the series of the label values
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds v (not negative) to the series of the label values
+func (c *Counter) Add(v float64, values ...string) {
+	key := c.key(values)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s, ok := c.series[key]
+	if !ok {
+		s = &seriesCounter{values: append([]string(nil), values...)}
+		c.series[key] = s
+	}
+	s.value += v
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.writeHeader(w)
+	for _, key := range sortedKeys(c.series) {
+		s := c.series[key]
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.formatLabels(s.values, "", ""), formatFloat(s.value))
+	}
+}
+
+// Histogram is a metric that counts observations in buckets, by label values
+type Histogram struct {
+	desc
+	// buckets are the upper bounds of the buckets
+	buckets []float64
+	// mu guards series
+	mu sync.Mutex
+	// series are the series by key of their label values
+	series map[string]*seriesHistogram
+}
+
+// seriesHistogram is a series of a histogram
+type seriesHistogram struct {
+	// values are the label values
+	values	[]string
+	// counts are the observations of each bucket (not cumulative)
+	counts	[]uint64
+	// count is the number of observations
+	count	uint64
+	// sum is the sum of the observations
+	sum		float64
+}
+
+// Observe adds an observation to the series of the label values
+func (h *Histogram) Observe(v float64, values ...string) {
+	key := h.key(values)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.series[key]
+	if !ok {
+		s = &seriesHistogram{values: append([]string(nil), values...), counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += v
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.writeHeader(w)
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+
+		// buckets (cumulative)
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.formatLabels(s.values, "le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.formatLabels(s.values, "le", "+Inf"), s.count)
+
+		// sum and count
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.formatLabels(s.values, "", ""), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.formatLabels(s.values, "", ""), s.count)
+	}
+}
+
+// metricFunc is a metric without labels whose value is read when it is written
+type metricFunc struct {
+	desc
+	// fn returns the value
+	fn func() float64
+}
+
+func (m *metricFunc) write(w *bufio.Writer) {
+	m.writeHeader(w)
+	fmt.Fprintf(w, "%s %s\n", m.name, formatFloat(m.fn()))
+}
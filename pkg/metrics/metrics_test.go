@@ -0,0 +1,128 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Tests for Registry.Write method
+func TestRegistry_Write(t *testing.T) {
+	t.Run("counter", func(t *testing.T) {
+		// arrange
+		r := NewRegistry()
+		c := r.Counter("http_requests_total", "Requests served.", "route", "status")
+		c.Inc("/products/{id}", "200")
+		c.Inc("/products/{id}", "200")
+		c.Add(3, "/products", "500")
+		var b strings.Builder
+
+		// act
+		err := r.Write(&b)
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, "# HELP http_requests_total Requests served.\n"+
+			"# TYPE http_requests_total counter\n"+
+			"http_requests_total{route=\"/products\",status=\"500\"} 3\n"+
+			"http_requests_total{route=\"/products/{id}\",status=\"200\"} 2\n", b.String())
+	})
+
+	t.Run("histogram", func(t *testing.T) {
+		// arrange
+		r := NewRegistry()
+		h := r.Histogram("op_duration_seconds", "Duration.", []float64{0.1, 1}, "op")
+		h.Observe(0.05, "get")
+		h.Observe(0.1, "get")
+		h.Observe(0.5, "get")
+		h.Observe(2, "get")
+		var b strings.Builder
+
+		// act
+		err := r.Write(&b)
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, "# HELP op_duration_seconds Duration.\n"+
+			"# TYPE op_duration_seconds histogram\n"+
+			"op_duration_seconds_bucket{op=\"get\",le=\"0.1\"} 2\n"+
+			"op_duration_seconds_bucket{op=\"get\",le=\"1\"} 3\n"+
+			"op_duration_seconds_bucket{op=\"get\",le=\"+Inf\"} 4\n"+
+			"op_duration_seconds_sum{op=\"get\"} 2.65\n"+
+			"op_duration_seconds_count{op=\"get\"} 4\n", b.String())
+	})
+
+	t.Run("functions without labels", func(t *testing.T) {
+		// arrange
+		r := NewRegistry()
+		r.GaugeFunc("db_open_connections", "Open connections.", func() float64 { return 3 })
+		r.CounterFunc("db_wait_total", "Waits\nfor a connection.", func() float64 { return 7 })
+		var b strings.Builder
+
+		// act
+		err := r.Write(&b)
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, "# HELP db_open_connections Open connections.\n"+
+			"# TYPE db_open_connections gauge\n"+
+			"db_open_connections 3\n"+
+			"# HELP db_wait_total Waits\\nfor a connection.\n"+
+			"# TYPE db_wait_total counter\n"+
+			"db_wait_total 7\n", b.String())
+	})
+
+	t.Run("label values are escaped", func(t *testing.T) {
+		// arrange
+		r := NewRegistry()
+		r.Counter("errors_total", "Errors.", "message").Inc("say \"hi\"\\\n")
+		var b strings.Builder
+
+		// act
+		err := r.Write(&b)
+
+		// assert
+		require.NoError(t, err)
+		require.Contains(t, b.String(), `errors_total{message="say \"hi\"\\\n"} 1`)
+	})
+}
+
+// Tests for Registry.Counter method
+func TestRegistry_Counter(t *testing.T) {
+	t.Run("registered twice", func(t *testing.T) {
+		// arrange
+		r := NewRegistry()
+		r.Counter("requests_total", "Requests.")
+
+		// act & assert
+		require.Panics(t, func() { r.Counter("requests_total", "Requests.") })
+	})
+
+	t.Run("wrong number of label values", func(t *testing.T) {
+		// arrange
+		c := NewRegistry().Counter("requests_total", "Requests.", "route")
+
+		// act & assert
+		require.Panics(t, func() { c.Inc() })
+	})
+}
+
+// Tests for Registry.Handler method
+func TestRegistry_Handler(t *testing.T) {
+	// arrange
+	r := NewRegistry()
+	r.GaugeFunc("up", "Up.", func() float64 { return 1 })
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	res := httptest.NewRecorder()
+
+	// act
+	r.Handler()(res, req)
+
+	// assert
+	require.Equal(t, http.StatusOK, res.Code)
+	require.Equal(t, ContentType, res.Header().Get("Content-Type"))
+	require.Equal(t, "# HELP up Up.\n# TYPE up gauge\nup 1\n", res.Body.String())
+}
@@ -0,0 +1,332 @@
+// Package migrate applies versioned sql migrations to a MySQL database
+// - migrations are pairs of files named <version>_<name>.up.sql and <version>_<name>.down.sql
+// - statements of a file are separated by a semicolon at the end of a line
+// - the applied migrations are tracked in the schema_migrations table
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMigrateInternal = errors.New("internal migrate error")
+	ErrMigrateInvalid = errors.New("migrate invalid migrations")
+	ErrMigrateLocked = errors.New("migrate locked by another process")
+)
+
+// Migration is a versioned change of the schema
+type Migration struct {
+	// Version orders the migrations, it is unique
+	Version	int
+	// Name describes the migration
+	Name	string
+	// Up is the sql that applies the migration
+	Up		string
+	// Down is the sql that reverts the migration
+	Down	string
+}
+
+// Status is the status of a migration in a database
+type Status struct {
+	// Migration is the migration
+	Migration *Migration
+	// Applied reports whether the migration is applied
+	Applied bool
+	// AppliedAt is when the migration was applied (zero if it is not)
+	AppliedAt time.Time
+}
+
+// rxFileMigration matches the file name of a migration: version, name and direction
+var rxFileMigration = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Load returns the migrations of the sql files of fsys (other files are ignored), ordered by version
+// - ErrMigrateInvalid if a version is repeated or lacks its up or down file
+func Load(fsys fs.FS) (ms []*Migration, err error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		err = fmt.Errorf("%w. %v", ErrMigrateInternal, err)
+		return
+	}
+
+	// read files
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		m := rxFileMigration.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || m == nil {
+			continue
+		}
+		version, _ := strconv.Atoi(m[1])
+
+		var content []byte
+		content, err = fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			err = fmt.Errorf("%w. %v", ErrMigrateInternal, err)
+			return
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != m[2] {
+			err = fmt.Errorf("%w. version %d has names %q and %q", ErrMigrateInvalid, version, migration.Name, m[2])
+			return
+		}
+		switch m[3] {
+		case "up":
+			migration.Up = string(content)
+		case "down":
+			migration.Down = string(content)
+		}
+	}
+
+	// check pairs
+	for _, migration := range byVersion {
+		if strings.TrimSpace(migration.Up) == "" || strings.TrimSpace(migration.Down) == "" {
+			err = fmt.Errorf("%w. version %d must have non-empty up and down files", ErrMigrateInvalid, migration.Version)
+			return
+		}
+		ms = append(ms, migration)
+	}
+	sort.Slice(ms, func(i, j int) bool { return ms[i].Version < ms[j].Version })
+	return
+}
+
+// Create writes the empty up and down files of a new migration in dir and returns their paths
+// - the version is the next one after the last migration of dir
+func Create(dir string, name string) (paths []string, err error) {
+	name = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), " ", "_"))
+	if !rxFileMigration.MatchString("1_" + name + ".up.sql") {
+		err = fmt.Errorf("%w. name %q must only have letters, digits and underscores", ErrMigrateInvalid, name)
+		return
+	}
+
+	ms, err := Load(os.DirFS(dir))
+	if err != nil {
+		return
+	}
+	version := 1
+	if len(ms) > 0 {
+		version = ms[len(ms)-1].Version + 1
+	}
+
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(dir, fmt.Sprintf("%04d_%s.%s.sql", version, name, direction))
+		content := fmt.Sprintf("-- %04d %s (%s)\n", version, name, direction)
+		err = os.WriteFile(path, []byte(content), 0o644)
+		if err != nil {
+			err = fmt.Errorf("%w. %v", ErrMigrateInternal, err)
+			return
+		}
+		paths = append(paths, path)
+	}
+	return
+}
+
+// NewMigrator returns new Migrator
+// - the dsn of db must have parseTime=true
+func NewMigrator(db *sql.DB, ms []*Migration) *Migrator {
+	return &Migrator{db: db, migrations: ms}
+}
+
+// Migrator applies and reverts migrations on a database
+// - a named lock serializes migrators of different processes on the same database
+type Migrator struct {
+	// db is the database
+	db *sql.DB
+	// migrations are the known migrations, ordered by version
+	migrations []*Migration
+}
+
+// lockMigrator is the name of the lock held while migrating, and lockTimeoutMigrator how long to wait for it
+const (
+	lockMigrator = "schema_migrations"
+	lockTimeoutMigrator = 60
+)
+
+// Up applies the pending migrations in order and returns them
+// - each migration is recorded as soon as it is applied, so a failure keeps the previous ones
+func (m *Migrator) Up(ctx context.Context) (applied []*Migration, err error) {
+	err = m.locked(ctx, func(conn *sql.Conn) (err error) {
+		done, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+
+			err = apply(ctx, conn, migration.Up)
+			if err != nil {
+				err = fmt.Errorf("%w. up %d_%s: %v", ErrMigrateInternal, migration.Version, migration.Name, err)
+				return
+			}
+			_, err = conn.ExecContext(ctx, "INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)", migration.Version, migration.Name, time.Now().UTC())
+			if err != nil {
+				err = fmt.Errorf("%w. %v", ErrMigrateInternal, err)
+				return
+			}
+			applied = append(applied, migration)
+		}
+		return
+	})
+	return
+}
+
+// Down reverts the last steps applied migrations, in reverse order, and returns them
+func (m *Migrator) Down(ctx context.Context, steps int) (reverted []*Migration, err error) {
+	err = m.locked(ctx, func(conn *sql.Conn) (err error) {
+		done, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := done[migration.Version]; !ok {
+				continue
+			}
+
+			err = apply(ctx, conn, migration.Down)
+			if err != nil {
+				err = fmt.Errorf("%w. down %d_%s: %v", ErrMigrateInternal, migration.Version, migration.Name, err)
+				return
+			}
+			_, err = conn.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = ?", migration.Version)
+			if err != nil {
+				err = fmt.Errorf("%w. %v", ErrMigrateInternal, err)
+				return
+			}
+			reverted = append(reverted, migration)
+		}
+		return
+	})
+	return
+}
+
+// Status returns the status of every known migration, ordered by version
+func (m *Migrator) Status(ctx context.Context) (statuses []*Status, err error) {
+	err = m.locked(ctx, func(conn *sql.Conn) (err error) {
+		done, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return
+		}
+
+		for _, migration := range m.migrations {
+			appliedAt, ok := done[migration.Version]
+			statuses = append(statuses, &Status{Migration: migration, Applied: ok, AppliedAt: appliedAt})
+		}
+		return
+	})
+	return
+}
+
+// locked runs fn on a connection that holds the migrations lock
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) (err error)) (err error) {
+	// connection (named locks belong to a session)
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		err = fmt.Errorf("%w. %v", ErrMigrateInternal, err)
+		return
+	}
+	defer conn.Close()
+
+	// lock
+	var locked sql.NullInt64
+	err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockMigrator, lockTimeoutMigrator).Scan(&locked)
+	if err != nil {
+		err = fmt.Errorf("%w. %v", ErrMigrateInternal, err)
+		return
+	}
+	if locked.Int64 != 1 {
+		err = ErrMigrateLocked
+		return
+	}
+	defer conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", lockMigrator)
+
+	// migrations table
+	_, err = conn.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS schema_migrations ("+
+		"version BIGINT NOT NULL PRIMARY KEY, "+
+		"name VARCHAR(255) NOT NULL, "+
+		"applied_at DATETIME(6) NOT NULL)")
+	if err != nil {
+		err = fmt.Errorf("%w. %v", ErrMigrateInternal, err)
+		return
+	}
+
+	return fn(conn)
+}
+
+// appliedMigrations returns when each applied migration was applied, by version
+func appliedMigrations(ctx context.Context, conn *sql.Conn) (done map[int]time.Time, err error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		err = fmt.Errorf("%w. %v", ErrMigrateInternal, err)
+		return
+	}
+	defer rows.Close()
+
+	done = make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err = rows.Scan(&version, &appliedAt); err != nil {
+			err = fmt.Errorf("%w. %v", ErrMigrateInternal, err)
+			return
+		}
+		done[version] = appliedAt
+	}
+	if rows.Err() != nil {
+		err = fmt.Errorf("%w. %v", ErrMigrateInternal, rows.Err())
+		return
+	}
+
+	return
+}
+
+// apply executes the statements of a migration file
+// - MySQL commits DDL statements implicitly, so a file is not atomic: keep one DDL statement per migration
+func apply(ctx context.Context, conn *sql.Conn, content string) (err error) {
+	for _, statement := range Statements(content) {
+		if _, err = conn.ExecContext(ctx, statement); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Statements splits the content of a migration file in statements
+// - a statement ends with a semicolon at the end of a line, lines starting with -- are comments
+func Statements(content string) (statements []string) {
+	var current []string
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current = append(current, line)
+
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSuffix(strings.TrimSpace(strings.Join(current, "\n")), ";"))
+			current = nil
+		}
+	}
+	if len(current) > 0 {
+		statements = append(statements, strings.TrimSpace(strings.Join(current, "\n")))
+	}
+	return
+}
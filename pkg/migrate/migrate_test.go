@@ -0,0 +1,153 @@
+package migrate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Tests for Load function
+func TestLoad(t *testing.T) {
+	type input struct { fsys fstest.MapFS }
+	type output struct { versions []int; err error }
+	type testCase struct {
+		name string
+		input input
+		output output
+	}
+
+	file := func(content string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(content)} }
+	cases := []testCase{
+		// valid cases
+		{
+			name: "ordered by version",
+			input: input{fsys: fstest.MapFS{
+				"0010_b.up.sql": file("CREATE TABLE b (id INT);"),
+				"0010_b.down.sql": file("DROP TABLE b;"),
+				"0002_a.up.sql": file("CREATE TABLE a (id INT);"),
+				"0002_a.down.sql": file("DROP TABLE a;"),
+			}},
+			output: output{versions: []int{2, 10}, err: nil},
+		},
+		{
+			name: "other files are ignored",
+			input: input{fsys: fstest.MapFS{
+				"0001_a.up.sql": file("CREATE TABLE a (id INT);"),
+				"0001_a.down.sql": file("DROP TABLE a;"),
+				"migrations.go": file("package migrations"),
+				"README.md": file("migrations"),
+			}},
+			output: output{versions: []int{1}, err: nil},
+		},
+		{name: "empty", input: input{fsys: fstest.MapFS{}}, output: output{versions: nil, err: nil}},
+
+		// invalid cases
+		{
+			name: "missing down",
+			input: input{fsys: fstest.MapFS{"0001_a.up.sql": file("CREATE TABLE a (id INT);")}},
+			output: output{err: ErrMigrateInvalid},
+		},
+		{
+			name: "empty up",
+			input: input{fsys: fstest.MapFS{"0001_a.up.sql": file("\n"), "0001_a.down.sql": file("DROP TABLE a;")}},
+			output: output{err: ErrMigrateInvalid},
+		},
+		{
+			name: "names of a version differ",
+			input: input{fsys: fstest.MapFS{"0001_a.up.sql": file("CREATE TABLE a (id INT);"), "0001_b.down.sql": file("DROP TABLE a;")}},
+			output: output{err: ErrMigrateInvalid},
+		},
+	}
+
+	// run tests
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// act
+			ms, err := Load(c.input.fsys)
+
+			// assert
+			require.ErrorIs(t, err, c.output.err)
+			var versions []int
+			for _, m := range ms {
+				versions = append(versions, m.Version)
+			}
+			require.Equal(t, c.output.versions, versions)
+		})
+	}
+}
+
+// Tests for Create function
+func TestCreate(t *testing.T) {
+	t.Run("next version", func(t *testing.T) {
+		// arrange
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "0001_a.up.sql"), []byte("CREATE TABLE a (id INT);"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "0001_a.down.sql"), []byte("DROP TABLE a;"), 0o644))
+
+		// act
+		paths, err := Create(dir, "Add Products Index")
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			filepath.Join(dir, "0002_add_products_index.up.sql"),
+			filepath.Join(dir, "0002_add_products_index.down.sql"),
+		}, paths)
+		for _, path := range paths {
+			require.FileExists(t, path)
+		}
+	})
+
+	t.Run("invalid name", func(t *testing.T) {
+		// act
+		paths, err := Create(t.TempDir(), "products-index")
+
+		// assert
+		require.ErrorIs(t, err, ErrMigrateInvalid)
+		require.Empty(t, paths)
+	})
+}
+
+// Tests for Statements function
+func TestStatements(t *testing.T) {
+	type input struct { content string }
+	type output struct { statements []string }
+	type testCase struct {
+		name string
+		input input
+		output output
+	}
+
+	cases := []testCase{
+		{
+			name: "multiline statements and comments",
+			input: input{content: "-- products\nCREATE TABLE a (\n  id INT\n);\n\n-- index\nCREATE INDEX ix ON a (id);\n"},
+			output: output{statements: []string{"CREATE TABLE a (\n  id INT\n)", "CREATE INDEX ix ON a (id)"}},
+		},
+		{
+			name: "last statement without semicolon",
+			input: input{content: "DROP TABLE a;\nDROP TABLE b"},
+			output: output{statements: []string{"DROP TABLE a", "DROP TABLE b"}},
+		},
+		{
+			name: "semicolon inside a line",
+			input: input{content: "INSERT INTO a (name) VALUES ('x;y');"},
+			output: output{statements: []string{"INSERT INTO a (name) VALUES ('x;y')"}},
+		},
+		{name: "only comments", input: input{content: "-- nothing\n"}, output: output{statements: nil}},
+	}
+
+	// run tests
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// act
+			statements := Statements(c.input.content)
+
+			// assert
+			require.Equal(t, c.output.statements, statements)
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package trace
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// NewExporterWriter returns new ExporterWriter
+func NewExporterWriter(w io.Writer) *ExporterWriter {
+	return &ExporterWriter{enc: json.NewEncoder(w)}
+}
+
+// ExporterWriter is an Exporter that writes each span as a JSON line, it works without a collector
+type ExporterWriter struct {
+	// mu serializes the writes, so lines are not interleaved
+	mu sync.Mutex
+	// enc writes the lines
+	enc *json.Encoder
+}
+
+// Export writes the span as a JSON line
+func (e *ExporterWriter) Export(span *SpanData) (err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.enc.Encode(span)
+}
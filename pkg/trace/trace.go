@@ -0,0 +1,260 @@
+// Package trace records spans of the work done for a request and exports them once they end
+// - the trace context is propagated with the W3C Trace Context traceparent header
+//   (https://www.w3.org/TR/trace-context/)
+// - spans are exported by a pluggable Exporter, ExporterWriter writes them as JSON lines (stderr, a file)
+// - a nil *Tracer and the spans it starts are no-ops, so tracing can be disabled without checks
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HeaderTraceparent is the header with the trace context of a request
+const HeaderTraceparent = "traceparent"
+
+// TraceID identifies a trace, the spans of a request across services
+type TraceID [16]byte
+
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanID identifies a span
+type SpanID [8]byte
+
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanContext is the part of a span propagated to its children, in the process or across services
+type SpanContext struct {
+	// TraceID is the trace of the span
+	TraceID	TraceID
+	// SpanID is the span
+	SpanID	SpanID
+	// Sampled reports whether the spans of the trace are exported
+	Sampled	bool
+}
+
+// IsValid reports whether the trace and span ids are set
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// Traceparent returns the traceparent header of the span context (version 00)
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// ParseTraceparent returns the span context of a traceparent header
+// - ok is false if the header is malformed, the trace is then restarted
+func ParseTraceparent(s string) (sc SpanContext, ok bool) {
+	// version-traceid-parentid-flags, later versions may append fields
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 {
+		return
+	}
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	if !isHex(version, 2) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return
+	}
+	if !isHex(traceID, 32) || !isHex(spanID, 16) || !isHex(flags, 2) {
+		return
+	}
+
+	hex.Decode(sc.TraceID[:], []byte(traceID))
+	hex.Decode(sc.SpanID[:], []byte(spanID))
+	var f [1]byte
+	hex.Decode(f[:], []byte(flags))
+	sc.Sampled = f[0]&0x01 == 0x01
+	ok = sc.IsValid()
+	return
+}
+
+// isHex reports whether s has n lowercase hex characters
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !('0' <= s[i] && s[i] <= '9' || 'a' <= s[i] && s[i] <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// keySpan is the context key of the current span
+type keySpan struct{}
+
+// keyRemote is the context key of the span context of a remote parent
+type keyRemote struct{}
+
+// ContextWithRemote returns ctx with the span context of a remote parent, the spans started with it are its children
+func ContextWithRemote(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, keyRemote{}, sc)
+}
+
+// SpanFromContext returns the current span of ctx (nil if there is none)
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(keySpan{}).(*Span)
+	return span
+}
+
+// SpanContextFromContext returns the span context of the current span of ctx, or of its remote parent
+func SpanContextFromContext(ctx context.Context) (sc SpanContext, ok bool) {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.Context(), true
+	}
+	sc, ok = ctx.Value(keyRemote{}).(SpanContext)
+	return
+}
+
+// Exporter exports the spans that ended
+type Exporter interface {
+	// Export exports a span, it may be called concurrently
+	Export(span *SpanData) (err error)
+}
+
+// NewTracer returns new Tracer
+// - onError is called with the errors of the exporter, it can be nil to ignore them
+func NewTracer(exporter Exporter, onError func(err error)) *Tracer {
+	return &Tracer{exporter: exporter, onError: onError}
+}
+
+// Tracer starts spans and exports them once they end
+type Tracer struct {
+	// exporter exports the spans
+	exporter Exporter
+	// onError is called with the errors of the exporter
+	onError func(err error)
+}
+
+// Start starts a span, child of the current span of ctx (or of its remote parent), and returns ctx with it
+// - a span without parent starts a new sampled trace, children keep the sampling of their parent
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+
+	span := &Span{tracer: t, data: SpanData{Name: name, Start: time.Now()}}
+	if parent, ok := SpanContextFromContext(ctx); ok {
+		span.sc.TraceID = parent.TraceID
+		span.sc.Sampled = parent.Sampled
+		span.data.ParentSpanID = parent.SpanID.String()
+	} else {
+		rand.Read(span.sc.TraceID[:])
+		span.sc.Sampled = true
+	}
+	rand.Read(span.sc.SpanID[:])
+	span.data.TraceID = span.sc.TraceID.String()
+	span.data.SpanID = span.sc.SpanID.String()
+
+	return context.WithValue(ctx, keySpan{}, span), span
+}
+
+// SpanData is the exported representation of a span
+type SpanData struct {
+	TraceID			string			`json:"trace_id"`
+	SpanID			string			`json:"span_id"`
+	ParentSpanID	string			`json:"parent_span_id,omitempty"`
+	Name			string			`json:"name"`
+	Start			time.Time		`json:"start"`
+	End				time.Time		`json:"end"`
+	DurationMs		float64			`json:"duration_ms"`
+	Attributes		map[string]any	`json:"attributes,omitempty"`
+	// Error is the error the work of the span failed with ("" if it succeeded)
+	Error			string			`json:"error,omitempty"`
+}
+
+// Span is the work done by an operation of a trace
+// - its methods can be called concurrently, and on a nil span (no-op)
+type Span struct {
+	// tracer exports the span once it ends
+	tracer *Tracer
+	// sc is the span context of the span
+	sc SpanContext
+	// mu guards data and ended
+	mu sync.Mutex
+	// data is the data exported
+	data SpanData
+	// ended is set once the span ended
+	ended bool
+}
+
+// Context returns the span context of the span
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetName replaces the name of the span, when it is only known once the work is done
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Name = name
+}
+
+// SetAttr sets an attribute of the span
+func (s *Span) SetAttr(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.data.Attributes == nil {
+		s.data.Attributes = make(map[string]any)
+	}
+	s.data.Attributes[key] = value
+}
+
+// RecordError marks the span as failed with err (nil is ignored)
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Error = err.Error()
+}
+
+// End ends the span and exports it if its trace is sampled, only the first call has effect
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	s.data.DurationMs = float64(s.data.End.Sub(s.data.Start).Microseconds()) / 1000
+	data := s.data
+	s.mu.Unlock()
+
+	if !s.sc.Sampled || s.tracer.exporter == nil {
+		return
+	}
+	if err := s.tracer.exporter.Export(&data); err != nil && s.tracer.onError != nil {
+		s.tracer.onError(err)
+	}
+}
@@ -0,0 +1,161 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// exporterRecorder is an Exporter that keeps the exported spans
+type exporterRecorder struct {
+	spans []*SpanData
+}
+
+func (e *exporterRecorder) Export(span *SpanData) (err error) {
+	e.spans = append(e.spans, span)
+	return
+}
+
+// Tests for ParseTraceparent function
+func TestParseTraceparent(t *testing.T) {
+	type input struct { header string }
+	type output struct { sc SpanContext; ok bool }
+	type testCase struct {
+		name string
+		input input
+		output output
+	}
+
+	sc := SpanContext{
+		TraceID: TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID: SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+		Sampled: true,
+	}
+	cases := []testCase{
+		// valid cases
+		{name: "sampled", input: input{header: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}, output: output{sc: sc, ok: true}},
+		{name: "not sampled", input: input{header: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"}, output: output{sc: SpanContext{TraceID: sc.TraceID, SpanID: sc.SpanID}, ok: true}},
+		{name: "later version with more fields", input: input{header: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"}, output: output{sc: sc, ok: true}},
+
+		// invalid cases
+		{name: "empty", input: input{header: ""}, output: output{ok: false}},
+		{name: "uppercase", input: input{header: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01"}, output: output{ok: false}},
+		{name: "zero trace id", input: input{header: "00-00000000000000000000000000000000-00f067aa0ba902b7-01"}, output: output{ok: false}},
+		{name: "zero span id", input: input{header: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01"}, output: output{ok: false}},
+		{name: "version ff", input: input{header: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}, output: output{ok: false}},
+		{name: "version 00 with more fields", input: input{header: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"}, output: output{ok: false}},
+		{name: "short trace id", input: input{header: "00-4bf92f3577b34da6-00f067aa0ba902b7-01"}, output: output{ok: false}},
+	}
+
+	// run tests
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// act
+			sc, ok := ParseTraceparent(c.input.header)
+
+			// assert
+			require.Equal(t, c.output.ok, ok)
+			if c.output.ok {
+				require.Equal(t, c.output.sc, sc)
+				require.Equal(t, c.input.header[3:55], sc.Traceparent()[3:55])
+			}
+		})
+	}
+}
+
+// Tests for Tracer.Start method
+func TestTracer_Start(t *testing.T) {
+	t.Run("children share the trace of their parent", func(t *testing.T) {
+		// arrange
+		exporter := &exporterRecorder{}
+		tracer := NewTracer(exporter, nil)
+
+		// act
+		ctx, parent := tracer.Start(context.Background(), "parent")
+		_, child := tracer.Start(ctx, "child")
+		child.SetAttr("db.system", "mysql")
+		child.RecordError(errors.New("timeout"))
+		child.End()
+		parent.End()
+		parent.End()
+
+		// assert
+		require.Len(t, exporter.spans, 2)
+		require.Equal(t, "child", exporter.spans[0].Name)
+		require.Equal(t, exporter.spans[1].TraceID, exporter.spans[0].TraceID)
+		require.Equal(t, exporter.spans[1].SpanID, exporter.spans[0].ParentSpanID)
+		require.Equal(t, "", exporter.spans[1].ParentSpanID)
+		require.Equal(t, map[string]any{"db.system": "mysql"}, exporter.spans[0].Attributes)
+		require.Equal(t, "timeout", exporter.spans[0].Error)
+	})
+
+	t.Run("remote parent", func(t *testing.T) {
+		// arrange
+		exporter := &exporterRecorder{}
+		tracer := NewTracer(exporter, nil)
+		remote, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+		// act
+		_, span := tracer.Start(ContextWithRemote(context.Background(), remote), "server")
+		span.End()
+
+		// assert
+		require.Len(t, exporter.spans, 1)
+		require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", exporter.spans[0].TraceID)
+		require.Equal(t, "00f067aa0ba902b7", exporter.spans[0].ParentSpanID)
+	})
+
+	t.Run("not sampled remote parent is not exported", func(t *testing.T) {
+		// arrange
+		exporter := &exporterRecorder{}
+		tracer := NewTracer(exporter, nil)
+		remote, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+
+		// act
+		_, span := tracer.Start(ContextWithRemote(context.Background(), remote), "server")
+		span.End()
+
+		// assert
+		require.Empty(t, exporter.spans)
+		require.Equal(t, remote.TraceID, span.Context().TraceID)
+	})
+
+	t.Run("nil tracer", func(t *testing.T) {
+		// arrange
+		var tracer *Tracer
+		ctx := context.Background()
+
+		// act
+		ctxSpan, span := tracer.Start(ctx, "noop")
+		span.SetAttr("key", "value")
+		span.RecordError(errors.New("error"))
+		span.End()
+
+		// assert
+		require.Nil(t, span)
+		require.Equal(t, ctx, ctxSpan)
+	})
+}
+
+// Tests for ExporterWriter.Export method
+func TestExporterWriter_Export(t *testing.T) {
+	// arrange
+	var buf bytes.Buffer
+	tracer := NewTracer(NewExporterWriter(&buf), nil)
+
+	// act
+	_, span := tracer.Start(context.Background(), "operation")
+	span.End()
+
+	// assert
+	var data map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &data))
+	require.Equal(t, "operation", data["name"])
+	require.Equal(t, span.Context().TraceID.String(), data["trace_id"])
+	require.Equal(t, span.Context().SpanID.String(), data["span_id"])
+	require.NotContains(t, data, "parent_span_id")
+}
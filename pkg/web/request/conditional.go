@@ -0,0 +1,86 @@
+package request
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// IfMatch reports whether the If-Match header of the request matches etag (strong comparison, RFC 9110 13.1.1)
+// - present is false when the request has no If-Match header
+// - etag empty means there is no current representation, so only a missing header matches
+func IfMatch(r *http.Request, etag string) (match bool, present bool) {
+	// get header
+	header := r.Header.Values("If-Match")
+	if len(header) == 0 {
+		match = true
+		return
+	}
+	present = true
+
+	// check etags
+	if etag == "" {
+		return
+	}
+	for _, tag := range etags(header) {
+		if tag == "*" || (!strings.HasPrefix(tag, "W/") && tag == etag) {
+			match = true
+			return
+		}
+	}
+
+	return
+}
+
+// IfNoneMatch reports whether the If-None-Match header of the request matches etag (weak comparison, RFC 9110 13.1.2)
+// - present is false when the request has no If-None-Match header
+func IfNoneMatch(r *http.Request, etag string) (match bool, present bool) {
+	// get header
+	header := r.Header.Values("If-None-Match")
+	if len(header) == 0 {
+		return
+	}
+	present = true
+
+	// check etags
+	if etag == "" {
+		return
+	}
+	for _, tag := range etags(header) {
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			match = true
+			return
+		}
+	}
+
+	return
+}
+
+// IfModifiedSince reports whether modTime is after the If-Modified-Since header of the request (RFC 9110 13.1.3)
+// - present is false when the request has no valid If-Modified-Since header, in that case modified is true
+// - modTime is truncated to seconds, the precision of http dates
+func IfModifiedSince(r *http.Request, modTime time.Time) (modified bool, present bool) {
+	// get header
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		modified = true
+		return
+	}
+	present = true
+
+	// compare
+	modified = modTime.Truncate(time.Second).After(since)
+	return
+}
+
+// etags splits the comma separated entity tags of the header values
+func etags(header []string) (tags []string) {
+	for _, h := range header {
+		for _, tag := range strings.Split(h, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return
+}
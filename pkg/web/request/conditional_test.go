@@ -0,0 +1,126 @@
+package request
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Tests for IfMatch function
+func TestIfMatch(t *testing.T) {
+	type input struct { header []string; etag string }
+	type output struct { match bool; present bool }
+	type testCase struct {
+		name string
+		input input
+		output output
+	}
+
+	cases := []testCase{
+		{name: "no header", input: input{header: nil, etag: `"1"`}, output: output{match: true, present: false}},
+		{name: "same etag", input: input{header: []string{`"1"`}, etag: `"1"`}, output: output{match: true, present: true}},
+		{name: "etag in list", input: input{header: []string{`"2", "1"`}, etag: `"1"`}, output: output{match: true, present: true}},
+		{name: "etag in several headers", input: input{header: []string{`"2"`, `"1"`}, etag: `"1"`}, output: output{match: true, present: true}},
+		{name: "wildcard", input: input{header: []string{`*`}, etag: `"1"`}, output: output{match: true, present: true}},
+		{name: "other etag", input: input{header: []string{`"2"`}, etag: `"1"`}, output: output{match: false, present: true}},
+		{name: "weak etag never matches", input: input{header: []string{`W/"1"`}, etag: `"1"`}, output: output{match: false, present: true}},
+		{name: "wildcard without representation", input: input{header: []string{`*`}, etag: ""}, output: output{match: false, present: true}},
+	}
+
+	// run tests
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// arrange
+			r := httptest.NewRequest(http.MethodPut, "/products/1", nil)
+			for _, h := range c.input.header {
+				r.Header.Add("If-Match", h)
+			}
+
+			// act
+			match, present := IfMatch(r, c.input.etag)
+
+			// assert
+			require.Equal(t, c.output.match, match)
+			require.Equal(t, c.output.present, present)
+		})
+	}
+}
+
+// Tests for IfNoneMatch function
+func TestIfNoneMatch(t *testing.T) {
+	type input struct { header []string; etag string }
+	type output struct { match bool; present bool }
+	type testCase struct {
+		name string
+		input input
+		output output
+	}
+
+	cases := []testCase{
+		{name: "no header", input: input{header: nil, etag: `"1"`}, output: output{match: false, present: false}},
+		{name: "same etag", input: input{header: []string{`"1"`}, etag: `"1"`}, output: output{match: true, present: true}},
+		{name: "weak etag matches", input: input{header: []string{`"2", W/"1"`}, etag: `"1"`}, output: output{match: true, present: true}},
+		{name: "wildcard", input: input{header: []string{`*`}, etag: `"1"`}, output: output{match: true, present: true}},
+		{name: "other etag", input: input{header: []string{`"2"`}, etag: `"1"`}, output: output{match: false, present: true}},
+	}
+
+	// run tests
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// arrange
+			r := httptest.NewRequest(http.MethodGet, "/products/1", nil)
+			for _, h := range c.input.header {
+				r.Header.Add("If-None-Match", h)
+			}
+
+			// act
+			match, present := IfNoneMatch(r, c.input.etag)
+
+			// assert
+			require.Equal(t, c.output.match, match)
+			require.Equal(t, c.output.present, present)
+		})
+	}
+}
+
+// Tests for IfModifiedSince function
+func TestIfModifiedSince(t *testing.T) {
+	modTime := time.Date(2023, 10, 1, 12, 0, 0, 500000000, time.UTC)
+
+	type input struct { header string }
+	type output struct { modified bool; present bool }
+	type testCase struct {
+		name string
+		input input
+		output output
+	}
+
+	cases := []testCase{
+		{name: "no header", input: input{header: ""}, output: output{modified: true, present: false}},
+		{name: "invalid date", input: input{header: "yesterday"}, output: output{modified: true, present: false}},
+		{name: "same second", input: input{header: "Sun, 01 Oct 2023 12:00:00 GMT"}, output: output{modified: false, present: true}},
+		{name: "later date", input: input{header: "Sun, 01 Oct 2023 13:00:00 GMT"}, output: output{modified: false, present: true}},
+		{name: "earlier date", input: input{header: "Sun, 01 Oct 2023 11:59:59 GMT"}, output: output{modified: true, present: true}},
+	}
+
+	// run tests
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// arrange
+			r := httptest.NewRequest(http.MethodGet, "/products/1", nil)
+			if c.input.header != "" {
+				r.Header.Set("If-Modified-Since", c.input.header)
+			}
+
+			// act
+			modified, present := IfModifiedSince(r, modTime)
+
+			// assert
+			require.Equal(t, c.output.modified, modified)
+			require.Equal(t, c.output.present, present)
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"regexp"
 	"strings"
@@ -24,6 +25,40 @@ func JSON(r *http.Request, ptr any) (err error) {
 	return
 }
 
+// JSONFields decodes json from request body to ptr and returns the set of fields present in the body
+// - a field present with a null value is in the set
+func JSONFields(r *http.Request, ptr any) (fields map[string]bool, err error) {
+	// get body
+	var body []byte
+	body, err = io.ReadAll(r.Body)
+	if err != nil {
+		err = fmt.Errorf("%w. %v", ErrRequestJSONInvalid, err)
+		return
+	}
+
+	// decode fields
+	var raw map[string]json.RawMessage
+	err = json.Unmarshal(body, &raw)
+	if err != nil {
+		err = fmt.Errorf("%w. %v", ErrRequestJSONInvalid, err)
+		return
+	}
+	fields = make(map[string]bool, len(raw))
+	for field := range raw {
+		fields[field] = true
+	}
+
+	// decode ptr
+	err = json.Unmarshal(body, ptr)
+	if err != nil {
+		fields = nil
+		err = fmt.Errorf("%w. %v", ErrRequestJSONInvalid, err)
+		return
+	}
+
+	return
+}
+
 // PathLastParam returns the value of the last path parameter
 var (
 	ErrRequestPathParamInvalid = errors.New("request path param invalid")
@@ -2,7 +2,9 @@ package request
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -66,4 +68,49 @@ func TestPathParam(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+// Tests for JSONFields function
+func TestJSONFields(t *testing.T) {
+	type body struct { Name string `json:"name"`; Count *int `json:"count"` }
+
+	t.Run("present and null fields", func(t *testing.T) {
+		// arrange
+		r := httptest.NewRequest(http.MethodPut, "/products/1", strings.NewReader(`{"name":"apple","count":null}`))
+
+		// act
+		var b body
+		fields, err := JSONFields(r, &b)
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, map[string]bool{"name": true, "count": true}, fields)
+		require.Equal(t, body{Name: "apple", Count: nil}, b)
+	})
+
+	t.Run("invalid json", func(t *testing.T) {
+		// arrange
+		r := httptest.NewRequest(http.MethodPut, "/products/1", strings.NewReader(`{"name":"apple"`))
+
+		// act
+		var b body
+		fields, err := JSONFields(r, &b)
+
+		// assert
+		require.ErrorIs(t, err, ErrRequestJSONInvalid)
+		require.Nil(t, fields)
+	})
+
+	t.Run("invalid field type", func(t *testing.T) {
+		// arrange
+		r := httptest.NewRequest(http.MethodPut, "/products/1", strings.NewReader(`{"name":"apple","count":"one"}`))
+
+		// act
+		var b body
+		fields, err := JSONFields(r, &b)
+
+		// assert
+		require.ErrorIs(t, err, ErrRequestJSONInvalid)
+		require.Nil(t, fields)
+	})
+}